@@ -0,0 +1,168 @@
+// Package bench runs the standard Lox benchmark programs, measures how long
+// they take and how much they allocate, and compares the results with a
+// baseline saved from an earlier run.
+package bench
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+//go:embed programs/*.lox
+var programs embed.FS
+
+// Program is a benchmark program.
+type Program struct {
+	Name   string
+	Source []byte
+}
+
+// Programs returns the benchmark programs sorted by name.
+func Programs() []Program {
+	entries, err := programs.ReadDir("programs")
+	if err != nil {
+		panic(err)
+	}
+	var list []Program
+	for _, entry := range entries {
+		source, err := programs.ReadFile("programs/" + entry.Name())
+		if err != nil {
+			panic(err)
+		}
+		list = append(list, Program{strings.TrimSuffix(entry.Name(), path.Ext(entry.Name())), source})
+	}
+	return list
+}
+
+// Options tells how to run the programs.
+type Options struct {
+	UseVM    bool
+	Optimize bool
+}
+
+// RunProgram tokenizes, parses, resolves and runs a program once on a new
+// interpreter, throwing away what it prints.
+func RunProgram(program Program, options Options) error {
+	in := lox.NewInterpreter()
+	in.Stdout = io.Discard
+	in.UseVM = options.UseVM
+	in.Optimize = options.Optimize
+	return in.Run(program.Source)
+}
+
+// Result is what running a program cost, on average over several runs.
+type Result struct {
+	Name   string        `json:"name"`
+	Time   time.Duration `json:"ns"`
+	Allocs uint64        `json:"allocs"`
+	Bytes  uint64        `json:"bytes"`
+}
+
+// Measure runs a program the given number of times and averages the time
+// and allocations of each run.
+func Measure(program Program, options Options, runs int) (Result, error) {
+	// A first run warms up the caches and isn't measured.
+	if err := RunProgram(program, options); err != nil {
+		return Result{}, fmt.Errorf("%s: %w", program.Name, err)
+	}
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < runs; i++ {
+		if err := RunProgram(program, options); err != nil {
+			return Result{}, fmt.Errorf("%s: %w", program.Name, err)
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return Result{
+		Name:   program.Name,
+		Time:   elapsed / time.Duration(runs),
+		Allocs: (after.Mallocs - before.Mallocs) / uint64(runs),
+		Bytes:  (after.TotalAlloc - before.TotalAlloc) / uint64(runs),
+	}, nil
+}
+
+// Baseline holds earlier results by program name.
+type Baseline map[string]Result
+
+// LoadBaseline reads a baseline saved by SaveBaseline.
+func LoadBaseline(filename string) (Baseline, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	baseline := make(Baseline)
+	for _, result := range results {
+		baseline[result.Name] = result
+	}
+	return baseline, nil
+}
+
+// SaveBaseline writes results to a file for later runs to compare with.
+func SaveBaseline(filename string, results []Result) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0o644)
+}
+
+// Threshold is how much slower a program must run, or how much more it must
+// allocate, than in the baseline for Report to flag it as a regression.
+const Threshold = 0.10
+
+// Report prints a table of results, compared with the baseline when there
+// is one, and returns the names of the programs that regressed.
+func Report(w io.Writer, results []Result, baseline Baseline) []string {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprint(tw, "program\ttime/run\tallocs/run\tbytes/run\t")
+	if baseline != nil {
+		fmt.Fprint(tw, "time\tallocs\t")
+	}
+	fmt.Fprintln(tw)
+	var regressed []string
+	for _, result := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t", result.Name, result.Time.Round(time.Microsecond), result.Allocs, result.Bytes)
+		if old, found := baseline[result.Name]; found {
+			timeChange := change(float64(old.Time), float64(result.Time))
+			allocsChange := change(float64(old.Allocs), float64(result.Allocs))
+			fmt.Fprintf(tw, "%+.1f%%\t%+.1f%%\t", 100*timeChange, 100*allocsChange)
+			if timeChange > Threshold || allocsChange > Threshold {
+				fmt.Fprint(tw, "regressed")
+				regressed = append(regressed, result.Name)
+			}
+		} else if baseline != nil {
+			fmt.Fprint(tw, "-\t-\t")
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+	return regressed
+}
+
+// change is the relative change from before to after.
+func change(before, after float64) float64 {
+	if before == 0 {
+		if after == 0 {
+			return 0
+		}
+		return 1
+	}
+	return (after - before) / before
+}
@@ -0,0 +1,75 @@
+package bench
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+func BenchmarkPrograms(b *testing.B) {
+	modes := []struct {
+		name    string
+		options Options
+	}{
+		{"tree-walker", Options{}},
+		{"vm", Options{UseVM: true}},
+	}
+	for _, program := range Programs() {
+		for _, mode := range modes {
+			b.Run(program.Name+"/"+mode.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if err := RunProgram(program, mode.options); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestProgramsAgree checks every benchmark program runs and prints the same
+// on the tree-walker and the VM, with and without the optimizer.
+func TestProgramsAgree(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the benchmark programs take a while")
+	}
+	for _, program := range Programs() {
+		var outputs []string
+		for _, options := range []Options{{}, {UseVM: true}, {Optimize: true}, {UseVM: true, Optimize: true}} {
+			in := lox.NewInterpreter()
+			var out bytes.Buffer
+			in.Stdout = &out
+			in.UseVM, in.Optimize = options.UseVM, options.Optimize
+			if err := in.Run(program.Source); err != nil {
+				t.Fatalf("%s: %v", program.Name, err)
+			}
+			outputs = append(outputs, out.String())
+		}
+		if outputs[0] == "" || outputs[1] != outputs[0] || outputs[2] != outputs[0] || outputs[3] != outputs[0] {
+			t.Errorf("%s printed %q", program.Name, outputs)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	results := []Result{
+		{Name: "fib", Time: 100, Allocs: 10, Bytes: 1000},
+		{Name: "zoo", Time: 200, Allocs: 20, Bytes: 2000},
+		{Name: "new", Time: 300, Allocs: 30, Bytes: 3000},
+	}
+	baseline := Baseline{
+		"fib": {Name: "fib", Time: 100, Allocs: 10},
+		"zoo": {Name: "zoo", Time: 150, Allocs: 20},
+	}
+	var out strings.Builder
+	regressed := Report(&out, results, baseline)
+	if len(regressed) != 1 || regressed[0] != "zoo" {
+		t.Errorf("regressed: %v", regressed)
+	}
+	if !strings.Contains(out.String(), "+33.3%") || !strings.Contains(out.String(), "regressed") {
+		t.Errorf("reported:\n%s", out.String())
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/codecrafters-io/interpreter-starter-go/bench"
+)
+
+// runBench measures the benchmark programs named in args, or all of them,
+// and compares the results with a baseline file when one is given. It exits
+// with 1 when a program regressed past bench.Threshold.
+func runBench(args []string) {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	useVM := flags.Bool("vm", false, "run on the bytecode VM instead of the tree-walker")
+	optimize := flags.Bool("O", false, "optimize the syntax tree before running")
+	runs := flags.Int("runs", 5, "how many times to run each program")
+	baselineFile := flags.String("baseline", "", "compare with the results saved in this file")
+	saveFile := flags.String("save", "", "save the results to this file")
+	flags.Parse(args)
+	if *runs < 1 {
+		fmt.Fprintln(os.Stderr, "The number of runs must be at least 1.")
+		os.Exit(1)
+	}
+
+	programs := bench.Programs()
+	if flags.NArg() > 0 {
+		byName := make(map[string]bench.Program)
+		for _, program := range programs {
+			byName[program.Name] = program
+		}
+		programs = nil
+		for _, name := range flags.Args() {
+			program, found := byName[name]
+			if !found {
+				fmt.Fprintf(os.Stderr, "Unknown benchmark: %s\n", name)
+				os.Exit(1)
+			}
+			programs = append(programs, program)
+		}
+	}
+
+	var baseline bench.Baseline
+	if *baselineFile != "" {
+		var err error
+		baseline, err = bench.LoadBaseline(*baselineFile)
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "No baseline in %s yet.\n", *baselineFile)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading baseline: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	options := bench.Options{UseVM: *useVM, Optimize: *optimize}
+	var results []bench.Result
+	for _, program := range programs {
+		result, err := bench.Measure(program, options, *runs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error running benchmark: %v\n", err)
+			os.Exit(1)
+		}
+		results = append(results, result)
+	}
+	regressed := bench.Report(os.Stdout, results, baseline)
+
+	if *saveFile != "" {
+		if err := bench.SaveBaseline(*saveFile, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving baseline: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if len(regressed) > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d benchmarks regressed by more than %.0f%%.\n", len(regressed), len(results), 100*bench.Threshold)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// newLineReader returns a line editor when stdin is a terminal, or a plain
+// reader without prompts when input is piped in.
+func newLineReader(complete func(text string) []string) lineReader {
+	if isTerminal(int(os.Stdin.Fd())) {
+		return &lineEditor{
+			in:       bufio.NewReader(os.Stdin),
+			out:      os.Stdout,
+			fd:       int(os.Stdin.Fd()),
+			complete: complete,
+		}
+	}
+	return &plainReader{bufio.NewScanner(os.Stdin)}
+}
+
+type plainReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *plainReader) readLine(prompt string) (string, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// lineEditor reads lines from a terminal in raw mode. It supports moving the
+// cursor, browsing the history with the arrow keys and completing names with
+// tab.
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int
+	history  []string
+	complete func(text string) []string
+}
+
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	line := []rune{}
+	cursor := 0
+	historyIndex := len(e.history)
+	editing := ""
+	refresh := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+		if back := len(line) - cursor; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	setLine := func(s string) {
+		line = []rune(s)
+		cursor = len(line)
+	}
+
+	refresh()
+	for {
+		ch, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch ch {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			if strings.TrimSpace(string(line)) != "" {
+				e.history = append(e.history, string(line))
+			}
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if cursor < len(line) {
+				line = append(line[:cursor], line[cursor+1:]...)
+			}
+		case 127, 8: // Backspace
+			if cursor > 0 {
+				line = append(line[:cursor-1], line[cursor:]...)
+				cursor--
+			}
+		case 1: // Ctrl-A
+			cursor = 0
+		case 5: // Ctrl-E
+			cursor = len(line)
+		case 11: // Ctrl-K
+			line = line[:cursor]
+		case 21: // Ctrl-U
+			line = line[cursor:]
+			cursor = 0
+		case '\t':
+			text := string(line[:cursor])
+			completed := e.completeText(text, prompt)
+			line = append([]rune(completed), line[cursor:]...)
+			cursor = len([]rune(completed))
+		case 27: // Escape sequences for the arrow, home, end and delete keys
+			if next, _, _ := e.in.ReadRune(); next != '[' && next != 'O' {
+				break
+			}
+			key, _, _ := e.in.ReadRune()
+			switch key {
+			case 'A':
+				if historyIndex > 0 {
+					if historyIndex == len(e.history) {
+						editing = string(line)
+					}
+					historyIndex--
+					setLine(e.history[historyIndex])
+				}
+			case 'B':
+				if historyIndex < len(e.history) {
+					historyIndex++
+					if historyIndex == len(e.history) {
+						setLine(editing)
+					} else {
+						setLine(e.history[historyIndex])
+					}
+				}
+			case 'C':
+				cursor = min(cursor+1, len(line))
+			case 'D':
+				cursor = max(cursor-1, 0)
+			case 'H':
+				cursor = 0
+			case 'F':
+				cursor = len(line)
+			case '3':
+				e.in.ReadRune() // the trailing '~'
+				if cursor < len(line) {
+					line = append(line[:cursor], line[cursor+1:]...)
+				}
+			}
+		default:
+			if ch >= ' ' {
+				line = append(line[:cursor], append([]rune{ch}, line[cursor:]...)...)
+				cursor++
+			}
+		}
+		refresh()
+	}
+}
+
+// completeText extends text with the completion candidates. When the
+// candidates don't share a longer prefix they are listed below the prompt.
+func (e *lineEditor) completeText(text string, prompt string) string {
+	candidates := e.complete(text)
+	if len(candidates) == 0 {
+		fmt.Fprint(e.out, "\a")
+		return text
+	}
+	common := candidates[0]
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, common) {
+			_, size := utf8.DecodeLastRuneInString(common)
+			common = common[:len(common)-size]
+		}
+	}
+	if len(common) > len(text) || len(candidates) == 1 {
+		return common
+	}
+	names := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		names[i] = candidate[lastWordStart(candidate):]
+	}
+	fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(names, "  "))
+	return text
+}
+
+func lastWordStart(text string) int {
+	i := len(text)
+	for i > 0 {
+		ch, size := utf8.DecodeLastRuneInString(text[:i])
+		if !lox.IsIdentifierPart(ch) {
+			break
+		}
+		i -= size
+	}
+	return i
+}
@@ -1,19 +1,39 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
 )
 
 func main() {
-	if len(os.Args) < 3 {
+	if len(os.Args) < 2 || os.Args[1] == "repl" {
+		runREPL()
+		return
+	}
+	if os.Args[1] == "bench" {
+		runBench(os.Args[2:])
+		return
+	}
+	command := os.Args[1]
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	useVM := flags.Bool("vm", false, "run on the bytecode VM instead of the tree-walker")
+	optimize := flags.Bool("O", false, "optimize the syntax tree before running or printing it")
+	maxDepth := flags.Int("max-depth", lox.DefaultMaxDepth, "how deeply calls can nest before a stack overflow")
+	flags.Parse(os.Args[2:])
+	if flags.NArg() != 1 {
 		fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh <command> <filename>")
+		fmt.Fprintln(os.Stderr, "       ./your_program.sh parse [-O] <filename>")
+		fmt.Fprintln(os.Stderr, "       ./your_program.sh run [--vm] [-O] [--max-depth n] <filename>")
+		fmt.Fprintln(os.Stderr, "       ./your_program.sh bench [--vm] [-O] [--runs n] [--baseline file] [--save file] [program...]")
+		fmt.Fprintln(os.Stderr, "       ./your_program.sh [repl]")
 		os.Exit(1)
 	}
 
-	command := os.Args[1]
-
-	filename := os.Args[2]
+	filename := flags.Arg(0)
 	fileContents, err := os.ReadFile(filename)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
@@ -22,30 +42,66 @@ func main() {
 
 	switch command {
 	case "tokenize":
-		tokenizer(fileContents, true)
+		tokens, err := lox.Tokenize(fileContents)
+		for _, token := range tokens {
+			fmt.Println(token)
+		}
+		exitOnError(err, filename, fileContents)
 	case "parse":
-		tokens := tokenizer(fileContents, false)
-		parser := NewParser(tokens)
-		expr := parser.expression()
+		tokens, err := lox.Tokenize(fileContents)
+		exitOnError(err, filename, fileContents)
+		parser := lox.NewParser(tokens)
+		expr, err := parser.ParseExpression()
+		exitOnError(err, filename, fileContents)
+		if *optimize {
+			expr = expr.Optimize()
+		}
 		fmt.Println(expr)
 	case "evaluate":
-		tokens := tokenizer(fileContents, false)
-		parser := NewParser(tokens)
-		expr := parser.expression()
-		result := expr.Evaluate()
+		tokens, err := lox.Tokenize(fileContents)
+		exitOnError(err, filename, fileContents)
+		parser := lox.NewParser(tokens)
+		expr, err := parser.ParseExpression()
+		exitOnError(err, filename, fileContents)
+		result, err := lox.NewInterpreter().Evaluate(expr)
+		exitOnError(err, filename, fileContents)
 		if result == nil {
 			fmt.Println("nil")
 		} else {
 			fmt.Println(result)
 		}
 	case "run":
-		tokens := tokenizer(fileContents, false)
-		parser := NewParser(tokens)
-		statements := parser.parse()
-		resolveStatements(statements)
-		runStatements(statements)
+		in := lox.NewInterpreter()
+		in.UseVM = *useVM
+		in.Optimize = *optimize
+		in.MaxDepth = *maxDepth
+		exitOnError(in.Run(fileContents), filename, fileContents)
+	case "disassemble":
+		tokens, err := lox.Tokenize(fileContents)
+		exitOnError(err, filename, fileContents)
+		statements, err := lox.NewParser(tokens).Parse()
+		exitOnError(err, filename, fileContents)
+		in := lox.NewInterpreter()
+		exitOnError(in.Resolve(statements), filename, fileContents)
+		script, err := in.Compile(statements)
+		exitOnError(err, filename, fileContents)
+		lox.Disassemble(os.Stdout, script)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
 		os.Exit(1)
 	}
 }
+
+// exitOnError reports err and exits with 70 for runtime errors or 65 for
+// errors found before the program started running.
+func exitOnError(err error, filename string, source []byte) {
+	if err == nil {
+		return
+	}
+	fmt.Fprint(os.Stderr, lox.FormatError(err, filename, source))
+	var runtimeError *lox.RuntimeError
+	if errors.As(err, &runtimeError) {
+		os.Exit(70)
+	}
+	os.Exit(65)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+// runREPL reads entries from stdin and runs them one at a time, keeping the
+// globals from one entry to the next. Bare expressions have their value
+// printed, and errors are reported without ending the session.
+func runREPL() {
+	session := lox.NewSession(lox.NewInterpreter())
+	reader := newLineReader(session.Completions)
+	for {
+		entry, err := readEntry(reader, session)
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		result, echo, err := session.Eval(entry)
+		if err != nil {
+			fmt.Fprint(os.Stderr, lox.FormatError(err, "repl", session.Source()))
+			continue
+		}
+		if echo {
+			fmt.Println(result)
+		}
+	}
+}
+
+// readEntry reads lines until they make up an entry with no unclosed braces,
+// parentheses or strings.
+func readEntry(reader lineReader, session *lox.Session) (string, error) {
+	var lines []string
+	prompt := "> "
+	for {
+		line, err := reader.readLine(prompt)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+		entry := strings.Join(lines, "\n")
+		if !session.Incomplete(entry) {
+			return entry, nil
+		}
+		prompt = "... "
+	}
+}
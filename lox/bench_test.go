@@ -0,0 +1,111 @@
+package lox
+
+import (
+	"io"
+	"testing"
+)
+
+// benchmarkProgram runs a program once per iteration on a fresh interpreter.
+func benchmarkProgram(b *testing.B, source string) {
+	tokens, err := Tokenize([]byte(source))
+	if err != nil {
+		b.Fatal(err)
+	}
+	statements, err := NewParser(tokens).Parse()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		in := NewInterpreter()
+		in.Stdout = io.Discard
+		if err := in.Resolve(statements); err != nil {
+			b.Fatal(err)
+		}
+		if err := in.Execute(statements); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLocals(b *testing.B) {
+	benchmarkProgram(b, `
+		fun run() {
+			var sum = 0;
+			for (var i = 0; i < 10000; i = i + 1) {
+				var a = i;
+				{
+					var b = a * 2;
+					sum = sum + a + b;
+				}
+			}
+			return sum;
+		}
+		print run();
+	`)
+}
+
+func BenchmarkGlobals(b *testing.B) {
+	benchmarkProgram(b, `
+		var sum = 0;
+		var i = 0;
+		while (i < 10000) {
+			sum = sum + i;
+			i = i + 1;
+		}
+		print sum;
+	`)
+}
+
+func BenchmarkClosures(b *testing.B) {
+	benchmarkProgram(b, `
+		fun counter() {
+			var count = 0;
+			fun increment() {
+				count = count + 1;
+				return count;
+			}
+			return increment;
+		}
+		var next = counter();
+		for (var i = 0; i < 10000; i = i + 1) next();
+		print next();
+	`)
+}
+
+func BenchmarkFib(b *testing.B) {
+	benchmarkProgram(b, `
+		fun fib(n) {
+			if (n < 2) return n;
+			return fib(n - 2) + fib(n - 1);
+		}
+		print fib(18);
+	`)
+}
+
+func BenchmarkMethods(b *testing.B) {
+	benchmarkProgram(b, `
+		class Counter {
+			init() { this.count = 0; }
+			add(n) { this.count = this.count + n; }
+		}
+		class Doubler < Counter {
+			add(n) { super.add(n * 2); }
+		}
+		var counters = Counter();
+		var doublers = Doubler();
+		for (var i = 0; i < 5000; i = i + 1) {
+			counters.add(i);
+			doublers.add(i);
+		}
+		print counters.count + doublers.count;
+	`)
+}
+
+func BenchmarkConcatenation(b *testing.B) {
+	benchmarkProgram(b, `
+		var s = "";
+		for (var i = 0; i < 10000; i = i + 1) s = s + "line ";
+		print s == s + "";
+	`)
+}
@@ -0,0 +1,166 @@
+package lox
+
+import (
+	"fmt"
+	"maps"
+)
+
+type LoxCallable interface {
+	Call(in *Interpreter, arguments []Value) Value
+	Arity() int
+	String() string
+}
+
+// Method is a function declared in a class body. The tree-walker and the VM
+// each have their own representation of functions.
+type Method interface {
+	LoxCallable
+	// Bind returns the method with this bound to the instance.
+	Bind(instance *LoxInstance) LoxCallable
+	// callMethod calls the method with this bound to the receiver, saving
+	// the bound method Bind would create.
+	callMethod(in *Interpreter, receiver *LoxInstance, arguments []Value) Value
+	// declaringClass returns the class whose body declares the method.
+	declaringClass() *LoxClass
+}
+
+type LoxFunction struct {
+	declaration   *FunctionDeclaration
+	upvalues      []*Cell
+	isInitializer bool
+	class         *LoxClass
+	// receiver is the instance a method is bound to.
+	receiver *LoxInstance
+}
+
+func (f *LoxFunction) Arity() int {
+	return len(f.declaration.Params)
+}
+
+func (f *LoxFunction) String() string {
+	return fmt.Sprintf("<fn %s>", f.declaration.Name.Str)
+}
+
+func (f *LoxFunction) Call(in *Interpreter, arguments []Value) Value {
+	return f.callMethod(in, f.receiver, arguments)
+}
+
+func (f *LoxFunction) callMethod(in *Interpreter, receiver *LoxInstance, arguments []Value) Value {
+	depth := len(in.tasks)
+	in.enter(f, receiver, arguments)
+	in.run(depth)
+	return in.pop()
+}
+
+// tailCall is a call a return statement left for the function returning to
+// make; see returnCall.
+type tailCall struct {
+	function  *LoxFunction
+	receiver  *LoxInstance
+	arguments []Value
+	// paren is the call site.
+	paren *Token
+}
+
+// returnCall leaves a call in tail position to the function being returned
+// from, so that recursion in tail position runs in constant space. The
+// arguments are copied out of the slots they were evaluated into, which the
+// call site gives back to the stack.
+func (in *Interpreter) returnCall(function *LoxFunction, receiver *LoxInstance, arguments []Value, paren *Token) {
+	in.tailCall = tailCall{
+		function:  function,
+		receiver:  receiver,
+		arguments: append(in.tailCall.arguments[:0], arguments...),
+		paren:     paren,
+	}
+}
+
+func (f *LoxFunction) Bind(instance *LoxInstance) LoxCallable {
+	bound := *f
+	bound.receiver = instance
+	return &bound
+}
+
+func (f *LoxFunction) declaringClass() *LoxClass {
+	return f.class
+}
+
+type LoxClass struct {
+	name       string
+	superclass *LoxClass
+	// methods holds the inherited methods along with those the class
+	// declares, so finding a method takes a single lookup.
+	methods map[string]Method
+}
+
+// newClass creates a class that starts out with the methods of its
+// superclass. Classes don't change once declared, so the copies stay valid.
+func newClass(name string, superclass *LoxClass) *LoxClass {
+	methods := make(map[string]Method)
+	if superclass != nil {
+		maps.Copy(methods, superclass.methods)
+	}
+	return &LoxClass{name, superclass, methods}
+}
+
+func (c *LoxClass) FindMethod(name string) Method {
+	return c.methods[name]
+}
+
+func (c *LoxClass) Arity() int {
+	if initializer := c.FindMethod("init"); initializer != nil {
+		return initializer.Arity()
+	}
+	return 0
+}
+
+func (c *LoxClass) String() string {
+	return c.name
+}
+
+func (c *LoxClass) Call(in *Interpreter, arguments []Value) Value {
+	instance := &LoxInstance{c, make(map[string]Value)}
+	if initializer := c.FindMethod("init"); initializer != nil {
+		initializer.callMethod(in, instance, arguments)
+	}
+	return objectValue(instance)
+}
+
+type LoxInstance struct {
+	class  *LoxClass
+	fields map[string]Value
+}
+
+func (i *LoxInstance) String() string {
+	return i.class.String() + " instance"
+}
+
+// methodCache remembers the method a call site or property access found
+// for the class of the last instance it saw.
+type methodCache struct {
+	class  *LoxClass
+	method Method
+}
+
+func (mc *methodCache) lookUp(class *LoxClass, name string) Method {
+	if mc.class != class {
+		mc.class, mc.method = class, class.FindMethod(name)
+	}
+	return mc.method
+}
+
+// members lists the names of the fields and methods of the instance.
+func (i *LoxInstance) members() []string {
+	var names []string
+	for name := range i.fields {
+		names = append(names, name)
+	}
+	for name := range i.class.methods {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (i *LoxInstance) Set(name *Token, value Value) {
+	i.fields[name.Str] = value
+}
@@ -0,0 +1,249 @@
+package lox
+
+import (
+	"fmt"
+	"io"
+)
+
+// OpCode is a bytecode instruction of the VM. Operands follow the opcode in
+// the code: constant, slot, global and count operands take two bytes, big
+// endian, jump operands take four, and flags take one. OP_WIDE before an
+// instruction makes its first operand take four bytes, for constants past
+// the first 65536. Names of properties and methods are constants.
+type OpCode uint8
+
+const (
+	OP_CONSTANT OpCode = iota
+	OP_NIL
+	OP_TRUE
+	OP_FALSE
+	OP_POP
+	OP_GET_LOCAL
+	OP_SET_LOCAL
+	OP_GET_GLOBAL
+	OP_DEFINE_GLOBAL
+	OP_SET_GLOBAL
+	OP_GET_UPVALUE
+	OP_SET_UPVALUE
+	OP_GET_PROPERTY
+	OP_GET_METHOD
+	OP_CHECK_FIELDS
+	OP_SET_PROPERTY
+	OP_GET_SUPER
+	OP_EQUAL
+	OP_GREATER
+	OP_GREATER_EQUAL
+	OP_LESS
+	OP_LESS_EQUAL
+	OP_ADD
+	OP_SUBTRACT
+	OP_MULTIPLY
+	OP_DIVIDE
+	OP_NOT
+	OP_NEGATE
+	OP_PRINT
+	OP_JUMP
+	OP_JUMP_IF_FALSE
+	OP_LOOP
+	OP_CALL
+	OP_INVOKE
+	OP_TAIL_CALL
+	OP_TAIL_INVOKE
+	OP_CLOSURE
+	OP_CLOSE_UPVALUE
+	OP_RETURN
+	OP_CLASS
+	OP_METHOD
+	OP_LIST
+	OP_GET_INDEX
+	OP_SET_INDEX
+	OP_MAP
+	OP_INTERPOLATE
+	OP_WIDE
+)
+
+var opCodeNames = [...]string{
+	OP_CONSTANT:      "OP_CONSTANT",
+	OP_NIL:           "OP_NIL",
+	OP_TRUE:          "OP_TRUE",
+	OP_FALSE:         "OP_FALSE",
+	OP_POP:           "OP_POP",
+	OP_GET_LOCAL:     "OP_GET_LOCAL",
+	OP_SET_LOCAL:     "OP_SET_LOCAL",
+	OP_GET_GLOBAL:    "OP_GET_GLOBAL",
+	OP_DEFINE_GLOBAL: "OP_DEFINE_GLOBAL",
+	OP_SET_GLOBAL:    "OP_SET_GLOBAL",
+	OP_GET_UPVALUE:   "OP_GET_UPVALUE",
+	OP_SET_UPVALUE:   "OP_SET_UPVALUE",
+	OP_GET_PROPERTY:  "OP_GET_PROPERTY",
+	OP_GET_METHOD:    "OP_GET_METHOD",
+	OP_CHECK_FIELDS:  "OP_CHECK_FIELDS",
+	OP_SET_PROPERTY:  "OP_SET_PROPERTY",
+	OP_GET_SUPER:     "OP_GET_SUPER",
+	OP_EQUAL:         "OP_EQUAL",
+	OP_GREATER:       "OP_GREATER",
+	OP_GREATER_EQUAL: "OP_GREATER_EQUAL",
+	OP_LESS:          "OP_LESS",
+	OP_LESS_EQUAL:    "OP_LESS_EQUAL",
+	OP_ADD:           "OP_ADD",
+	OP_SUBTRACT:      "OP_SUBTRACT",
+	OP_MULTIPLY:      "OP_MULTIPLY",
+	OP_DIVIDE:        "OP_DIVIDE",
+	OP_NOT:           "OP_NOT",
+	OP_NEGATE:        "OP_NEGATE",
+	OP_PRINT:         "OP_PRINT",
+	OP_JUMP:          "OP_JUMP",
+	OP_JUMP_IF_FALSE: "OP_JUMP_IF_FALSE",
+	OP_LOOP:          "OP_LOOP",
+	OP_CALL:          "OP_CALL",
+	OP_INVOKE:        "OP_INVOKE",
+	OP_TAIL_CALL:     "OP_TAIL_CALL",
+	OP_TAIL_INVOKE:   "OP_TAIL_INVOKE",
+	OP_CLOSURE:       "OP_CLOSURE",
+	OP_CLOSE_UPVALUE: "OP_CLOSE_UPVALUE",
+	OP_RETURN:        "OP_RETURN",
+	OP_CLASS:         "OP_CLASS",
+	OP_METHOD:        "OP_METHOD",
+	OP_LIST:          "OP_LIST",
+	OP_GET_INDEX:     "OP_GET_INDEX",
+	OP_SET_INDEX:     "OP_SET_INDEX",
+	OP_MAP:           "OP_MAP",
+	OP_INTERPOLATE:   "OP_INTERPOLATE",
+	OP_WIDE:          "OP_WIDE",
+}
+
+func (op OpCode) String() string {
+	if int(op) < len(opCodeNames) {
+		return opCodeNames[op]
+	}
+	return fmt.Sprintf("OP_UNKNOWN(%d)", uint8(op))
+}
+
+// position is the source of an instruction. Token gives the line and is
+// where runtime errors are reported, and span is the text they underline.
+type position struct {
+	token *Token
+	span  Span
+}
+
+// Chunk is a sequence of instructions with the constants they use.
+type Chunk struct {
+	Code      []byte
+	Constants []Value
+	// positions has an entry for the first byte of every instruction.
+	positions []position
+}
+
+func (c *Chunk) line(offset int) int {
+	if token := c.positions[offset].token; token != nil {
+		return token.Line
+	}
+	return 0
+}
+
+func (c *Chunk) uint16At(offset int) int {
+	return int(c.Code[offset])<<8 | int(c.Code[offset+1])
+}
+
+func (c *Chunk) uint32At(offset int) int {
+	return c.uint16At(offset)<<16 | c.uint16At(offset+2)
+}
+
+// CompiledFunction is a function compiled to bytecode. The top-level code of
+// a program is compiled to a function named "script".
+type CompiledFunction struct {
+	Name          string
+	Arity         int
+	UpvalueCount  int
+	Chunk         Chunk
+	isInitializer bool
+	// globals names the global slots the code refers to.
+	globals *Globals
+}
+
+func (f *CompiledFunction) String() string {
+	if f.Name == "script" {
+		return "<script>"
+	}
+	return fmt.Sprintf("<fn %s>", f.Name)
+}
+
+// Disassemble lists the instructions of a compiled function, followed by
+// those of the functions it declares.
+func Disassemble(w io.Writer, function *CompiledFunction) {
+	chunk := &function.Chunk
+	globals := function.globals
+	fmt.Fprintf(w, "== %s ==\n", function)
+	line := -1
+	for offset := 0; offset < len(chunk.Code); {
+		fmt.Fprintf(w, "%04d ", offset)
+		if chunk.line(offset) == line {
+			fmt.Fprint(w, "   | ")
+		} else {
+			line = chunk.line(offset)
+			fmt.Fprintf(w, "%4d ", line)
+		}
+		offset = disassembleInstruction(w, chunk, globals, offset)
+	}
+	for _, constant := range chunk.Constants {
+		if nested, ok := constant.ref.(*CompiledFunction); ok {
+			fmt.Fprintln(w)
+			Disassemble(w, nested)
+		}
+	}
+}
+
+// disassembleInstruction prints the instruction at offset and returns the
+// offset of the next one. An instruction widened by OP_WIDE is printed with
+// it on one line.
+func disassembleInstruction(w io.Writer, chunk *Chunk, globals *Globals, offset int) int {
+	op := OpCode(chunk.Code[offset])
+	name, operand, next := op.String(), 0, offset+3
+	if op == OP_WIDE {
+		op = OpCode(chunk.Code[offset+1])
+		name, operand, next = "OP_WIDE "+op.String(), chunk.uint32At(offset+2), offset+6
+	} else if offset+2 < len(chunk.Code) {
+		operand = chunk.uint16At(offset + 1)
+	}
+	switch op {
+	case OP_GET_GLOBAL, OP_DEFINE_GLOBAL, OP_SET_GLOBAL:
+		fmt.Fprintf(w, "%-16s %4d '%s'\n", name, operand, globals.names[operand])
+		return next
+	case OP_CONSTANT, OP_GET_PROPERTY, OP_GET_METHOD, OP_SET_PROPERTY, OP_GET_SUPER, OP_METHOD:
+		fmt.Fprintf(w, "%-16s %4d '%s'\n", name, operand, chunk.Constants[operand])
+		return next
+	case OP_CLASS:
+		fmt.Fprintf(w, "%-16s %4d '%s'", name, operand, chunk.Constants[operand])
+		if chunk.Code[next] != 0 {
+			fmt.Fprint(w, " <")
+		}
+		fmt.Fprintln(w)
+		return next + 1
+	case OP_GET_LOCAL, OP_SET_LOCAL, OP_GET_UPVALUE, OP_SET_UPVALUE, OP_CALL, OP_INVOKE, OP_TAIL_CALL, OP_TAIL_INVOKE, OP_LIST, OP_MAP, OP_INTERPOLATE:
+		fmt.Fprintf(w, "%-16s %4d\n", name, operand)
+		return next
+	case OP_JUMP, OP_JUMP_IF_FALSE:
+		jump := chunk.uint32At(offset + 1)
+		fmt.Fprintf(w, "%-16s %4d -> %d\n", name, offset, offset+5+jump)
+		return offset + 5
+	case OP_LOOP:
+		jump := chunk.uint32At(offset + 1)
+		fmt.Fprintf(w, "%-16s %4d -> %d\n", name, offset, offset+5-jump)
+		return offset + 5
+	case OP_CLOSURE:
+		function := chunk.Constants[operand].ref.(*CompiledFunction)
+		fmt.Fprintf(w, "%-16s %4d %s\n", name, operand, function)
+		offset = next
+		for i := 0; i < function.UpvalueCount; i++ {
+			kind := "upvalue"
+			if chunk.Code[offset] != 0 {
+				kind = "local"
+			}
+			fmt.Fprintf(w, "%04d    |                     %s %d\n", offset, kind, chunk.uint16At(offset+1))
+			offset += 3
+		}
+		return offset
+	}
+	fmt.Fprintln(w, name)
+	return offset + 1
+}
@@ -0,0 +1,665 @@
+package lox
+
+import (
+	"errors"
+	"math"
+)
+
+// Compiler turns resolved statements into bytecode for the VM. There is one
+// compiler for each function being compiled, linked to the compiler of the
+// function it is nested in. Variables are looked up the same way the
+// resolver did, so the compiled code binds them to the same declarations.
+type Compiler struct {
+	enclosing    *Compiler
+	function     *CompiledFunction
+	functionType FunctionType
+	locals       []local
+	upvalues     []upvalue
+	scopeDepth   int
+	loops        []loop
+	// at is the token of the code being compiled, used for the line of
+	// instructions that can't fail.
+	at        *Token
+	constants map[any]int
+	globals   *Globals
+	errors    *[]error
+}
+
+type local struct {
+	name     string
+	depth    int
+	captured bool
+}
+
+// loop is a loop being compiled, with the jumps of the break and continue
+// statements in its body that are still to be patched.
+type loop struct {
+	statement *WhileStatement
+	// depth is the scope depth around the loop. Break and continue drop the
+	// locals declared deeper before they jump.
+	depth     int
+	breaks    []int
+	continues []int
+}
+
+type upvalue struct {
+	index   int
+	isLocal bool
+}
+
+func newCompiler(enclosing *Compiler, name string, functionType FunctionType) *Compiler {
+	c := &Compiler{
+		enclosing:    enclosing,
+		function:     &CompiledFunction{Name: name},
+		functionType: functionType,
+		constants:    make(map[any]int),
+	}
+	// Slot 0 holds the function being called, or the instance in methods.
+	if functionType == FT_METHOD || functionType == FT_INITIALIZER {
+		c.locals = append(c.locals, local{"this", 0, false})
+	} else {
+		c.locals = append(c.locals, local{"", 0, false})
+	}
+	if enclosing != nil {
+		c.at, c.globals, c.errors = enclosing.at, enclosing.globals, enclosing.errors
+	} else {
+		c.errors = new([]error)
+	}
+	return c
+}
+
+// Compile compiles resolved statements to a function that runs them as a
+// script. The statements must have been resolved without errors.
+func (in *Interpreter) Compile(statements []Stmt) (*CompiledFunction, error) {
+	c := newCompiler(nil, "script", FT_NONE)
+	c.at, c.globals = &Token{Line: 1}, in.globals
+	c.function.globals = in.globals
+	for _, statement := range statements {
+		statement.Compile(c)
+	}
+	c.emitReturn()
+	return c.function, errors.Join(*c.errors...)
+}
+
+// error records a compile error. Only the limits of the bytecode format can
+// cause one, since the resolver has already checked the program.
+func (c *Compiler) error(token *Token, msg string) {
+	*c.errors = append(*c.errors, &ResolveError{token, msg})
+}
+
+func (c *Compiler) chunk() *Chunk {
+	return &c.function.Chunk
+}
+
+// emitAt appends an instruction that can fail at run time, with the token
+// and span its errors are reported at.
+func (c *Compiler) emitAt(token *Token, span Span, op OpCode, operands ...byte) int {
+	chunk := c.chunk()
+	offset := len(chunk.Code)
+	chunk.Code = append(chunk.Code, byte(op))
+	chunk.Code = append(chunk.Code, operands...)
+	chunk.positions = append(chunk.positions, position{token, span})
+	for range operands {
+		chunk.positions = append(chunk.positions, position{})
+	}
+	return offset
+}
+
+func (c *Compiler) emit(op OpCode, operands ...byte) int {
+	return c.emitAt(c.at, tokenSpan(c.at), op, operands...)
+}
+
+func (c *Compiler) emitShort(op OpCode, operand int) int {
+	return c.emitShortAt(c.at, tokenSpan(c.at), op, operand)
+}
+
+// emitShortAt appends an instruction whose first operand takes two bytes,
+// followed by any flags. An operand too large for two bytes takes four, with
+// OP_WIDE before the instruction.
+func (c *Compiler) emitShortAt(token *Token, span Span, op OpCode, operand int, flags ...byte) int {
+	if operand <= math.MaxUint16 {
+		return c.emitAt(token, span, op, append(uint16Operand(operand), flags...)...)
+	}
+	c.emitAt(token, span, OP_WIDE)
+	return c.emitAt(token, span, op, append(uint32Operand(operand), flags...)...)
+}
+
+func uint16Operand(operand int) []byte {
+	return []byte{byte(operand >> 8), byte(operand)}
+}
+
+func uint32Operand(operand int) []byte {
+	return []byte{byte(operand >> 24), byte(operand >> 16), byte(operand >> 8), byte(operand)}
+}
+
+func (c *Compiler) emitReturn() {
+	if c.functionType == FT_INITIALIZER {
+		c.emitShort(OP_GET_LOCAL, 0)
+	} else {
+		c.emit(OP_NIL)
+	}
+	c.emit(OP_RETURN)
+}
+
+// makeConstant adds a value to the constants of the chunk, reusing the slot
+// of an identical number or an equal string. Numbers are told apart by their
+// bits, since 0 and -0 are equal but print differently.
+func (c *Compiler) makeConstant(value any) int {
+	key := value
+	if n, ok := value.(float64); ok {
+		key = math.Float64bits(n)
+	}
+	switch key.(type) {
+	case uint64, string:
+		if index, found := c.constants[key]; found {
+			return index
+		}
+	}
+	chunk := c.chunk()
+	index := len(chunk.Constants)
+	chunk.Constants = append(chunk.Constants, ValueOf(value))
+	switch key.(type) {
+	case uint64, string:
+		c.constants[key] = index
+	}
+	return index
+}
+
+func (c *Compiler) emitConstant(value any) {
+	c.emitShort(OP_CONSTANT, c.makeConstant(value))
+}
+
+// emitJump emits a jump with a placeholder offset, to be set by patchJump.
+// Offsets take four bytes, since how far a jump goes is only known once the
+// code it jumps over is compiled.
+func (c *Compiler) emitJump(op OpCode) int {
+	return c.emit(op, 0xff, 0xff, 0xff, 0xff)
+}
+
+func (c *Compiler) patchJump(offset int) {
+	jump := len(c.chunk().Code) - offset - 5
+	copy(c.chunk().Code[offset+1:], uint32Operand(jump))
+}
+
+func (c *Compiler) emitLoop(loopStart int) {
+	jump := len(c.chunk().Code) - loopStart + 5
+	c.emit(OP_LOOP, uint32Operand(jump)...)
+}
+
+func (c *Compiler) beginScope() {
+	c.scopeDepth++
+}
+
+func (c *Compiler) endScope() {
+	c.scopeDepth--
+	c.locals = c.locals[:len(c.locals)-c.popLocals(c.scopeDepth)]
+}
+
+// popLocals emits the code that drops the locals declared deeper than the
+// given scope depth from the stack, and returns how many there are. They
+// stay in scope for the compiler.
+func (c *Compiler) popLocals(depth int) int {
+	count := 0
+	for i := len(c.locals) - 1; i >= 0 && c.locals[i].depth > depth; i-- {
+		if c.locals[i].captured {
+			c.emit(OP_CLOSE_UPVALUE)
+		} else {
+			c.emit(OP_POP)
+		}
+		count++
+	}
+	return count
+}
+
+// loop returns the loop a break or continue statement applies to, which is
+// being compiled.
+func (c *Compiler) loop(statement *WhileStatement) *loop {
+	for i := len(c.loops) - 1; ; i-- {
+		if c.loops[i].statement == statement {
+			return &c.loops[i]
+		}
+	}
+}
+
+func (c *Compiler) addLocal(name *Token) {
+	if len(c.locals) > math.MaxUint16 {
+		c.error(name, "Too many local variables in function.")
+		return
+	}
+	c.locals = append(c.locals, local{name.Str, c.scopeDepth, false})
+}
+
+func (c *Compiler) resolveLocal(name string) int {
+	for i := len(c.locals) - 1; i >= 0; i-- {
+		if c.locals[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *Compiler) resolveUpvalue(name string) int {
+	if c.enclosing == nil {
+		return -1
+	}
+	if local := c.enclosing.resolveLocal(name); local != -1 {
+		c.enclosing.locals[local].captured = true
+		return c.addUpvalue(local, true)
+	}
+	if upvalue := c.enclosing.resolveUpvalue(name); upvalue != -1 {
+		return c.addUpvalue(upvalue, false)
+	}
+	return -1
+}
+
+func (c *Compiler) addUpvalue(index int, isLocal bool) int {
+	for i, upvalue := range c.upvalues {
+		if upvalue.index == index && upvalue.isLocal == isLocal {
+			return i
+		}
+	}
+	if len(c.upvalues) > math.MaxUint16 {
+		c.error(c.at, "Too many closure variables in function.")
+		return 0
+	}
+	c.upvalues = append(c.upvalues, upvalue{index, isLocal})
+	c.function.UpvalueCount = len(c.upvalues)
+	return len(c.upvalues) - 1
+}
+
+// getVariable emits the code that reads a variable, which is a local of the
+// function, one captured from an enclosing function, or else a global.
+func (c *Compiler) getVariable(name *Token) {
+	if slot := c.resolveLocal(name.Str); slot != -1 {
+		c.emitShort(OP_GET_LOCAL, slot)
+	} else if index := c.resolveUpvalue(name.Str); index != -1 {
+		c.emitShort(OP_GET_UPVALUE, index)
+	} else {
+		c.emitAt(name, tokenSpan(name), OP_GET_GLOBAL, uint16Operand(c.globalSlot(name))...)
+	}
+}
+
+func (c *Compiler) setVariable(name *Token) {
+	if slot := c.resolveLocal(name.Str); slot != -1 {
+		c.emitShort(OP_SET_LOCAL, slot)
+	} else if index := c.resolveUpvalue(name.Str); index != -1 {
+		c.emitShort(OP_SET_UPVALUE, index)
+	} else {
+		c.emitAt(name, tokenSpan(name), OP_SET_GLOBAL, uint16Operand(c.globalSlot(name))...)
+	}
+}
+
+func (c *Compiler) globalSlot(name *Token) int {
+	slot := c.globals.slot(name.Str)
+	if slot > math.MaxUint16 {
+		c.error(name, "Too many global variables.")
+	}
+	return slot
+}
+
+// defineVariable binds the value on top of the stack to a new variable. In
+// a local scope the value simply stays on the stack as the local's slot.
+func (c *Compiler) defineVariable(name *Token) {
+	if c.scopeDepth > 0 {
+		c.addLocal(name)
+		return
+	}
+	c.emitShort(OP_DEFINE_GLOBAL, c.globalSlot(name))
+}
+
+func (c *Compiler) compileFunction(f *FunctionDeclaration, functionType FunctionType) {
+	compiler := newCompiler(c, f.Name.Str, functionType)
+	compiler.function.globals = c.globals
+	compiler.function.Arity = len(f.Params)
+	compiler.function.isInitializer = functionType == FT_INITIALIZER
+	compiler.at = f.Name
+	compiler.beginScope()
+	for _, param := range f.Params {
+		compiler.addLocal(param)
+	}
+	for _, statement := range f.Body {
+		statement.Compile(compiler)
+	}
+	compiler.emitReturn()
+
+	c.at = f.Name
+	c.emitShort(OP_CLOSURE, c.makeConstant(compiler.function))
+	for _, upvalue := range compiler.upvalues {
+		isLocal := byte(0)
+		if upvalue.isLocal {
+			isLocal = 1
+		}
+		c.chunk().Code = append(c.chunk().Code, isLocal)
+		c.chunk().Code = append(c.chunk().Code, uint16Operand(upvalue.index)...)
+		c.chunk().positions = append(c.chunk().positions, position{}, position{}, position{})
+	}
+}
+
+func (s *PrintStatement) Compile(c *Compiler) {
+	s.Value.Compile(c)
+	c.emit(OP_PRINT)
+}
+
+func (s *ExpressionStatement) Compile(c *Compiler) {
+	s.Expr.Compile(c)
+	c.emit(OP_POP)
+}
+
+func (s *VarStatement) Compile(c *Compiler) {
+	c.at = s.Name
+	if s.Initializer != nil {
+		s.Initializer.Compile(c)
+	} else {
+		c.emit(OP_NIL)
+	}
+	c.at = s.Name
+	c.defineVariable(s.Name)
+}
+
+func (b *Block) Compile(c *Compiler) {
+	c.beginScope()
+	for _, statement := range b.Statements {
+		statement.Compile(c)
+	}
+	c.endScope()
+}
+
+func (s *IfStatement) Compile(c *Compiler) {
+	s.Condition.Compile(c)
+	thenJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emit(OP_POP)
+	s.ThenBranch.Compile(c)
+	elseJump := c.emitJump(OP_JUMP)
+	c.patchJump(thenJump)
+	c.emit(OP_POP)
+	if s.ElseBranch != nil {
+		s.ElseBranch.Compile(c)
+	}
+	c.patchJump(elseJump)
+}
+
+func (w *WhileStatement) Compile(c *Compiler) {
+	loopStart := len(c.chunk().Code)
+	w.Condition.Compile(c)
+	exitJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emit(OP_POP)
+	c.loops = append(c.loops, loop{statement: w, depth: c.scopeDepth})
+	w.Body.Compile(c)
+	loop := c.loops[len(c.loops)-1]
+	c.loops = c.loops[:len(c.loops)-1]
+	for _, jump := range loop.continues {
+		c.patchJump(jump)
+	}
+	if w.Increment != nil {
+		w.Increment.Compile(c)
+		c.emit(OP_POP)
+	}
+	c.emitLoop(loopStart)
+	c.patchJump(exitJump)
+	c.emit(OP_POP)
+	for _, jump := range loop.breaks {
+		c.patchJump(jump)
+	}
+}
+
+func (s *BreakStatement) Compile(c *Compiler) {
+	c.at = s.keyword
+	loop := c.loop(s.loop)
+	c.popLocals(loop.depth)
+	loop.breaks = append(loop.breaks, c.emitJump(OP_JUMP))
+}
+
+func (s *ContinueStatement) Compile(c *Compiler) {
+	c.at = s.keyword
+	loop := c.loop(s.loop)
+	c.popLocals(loop.depth)
+	loop.continues = append(loop.continues, c.emitJump(OP_JUMP))
+}
+
+func (f *FunctionDeclaration) Compile(c *Compiler) {
+	// A local function is in scope in its own body, so it can be recursive.
+	if c.scopeDepth > 0 {
+		c.addLocal(f.Name)
+	}
+	c.compileFunction(f, FT_FUNCTION)
+	if c.scopeDepth == 0 {
+		c.defineVariable(f.Name)
+	}
+}
+
+func (r *ReturnStatement) Compile(c *Compiler) {
+	c.at = r.keyword
+	if r.value == nil {
+		c.emitReturn()
+		return
+	}
+	r.value.Compile(c)
+	c.emit(OP_RETURN)
+}
+
+// Compile compiles a class declaration. The superclass is checked before
+// the class is bound to its name, as the tree-walker does, and stays on the
+// stack as the "super" local captured by the methods.
+func (d *ClassDeclaration) Compile(c *Compiler) {
+	c.at = d.Name
+	slot := -1
+	if c.scopeDepth > 0 {
+		c.emit(OP_NIL)
+		c.addLocal(d.Name)
+		slot = len(c.locals) - 1
+	}
+	hasSuperclass := byte(0)
+	if d.Superclass != nil {
+		hasSuperclass = 1
+		c.beginScope()
+		d.Superclass.Compile(c)
+		c.addLocal(&Token{Str: "super"})
+	}
+	c.emitShortAt(d.Name, c.superclassSpan(d), OP_CLASS, c.makeConstant(d.Name.Str), hasSuperclass)
+	if slot == -1 {
+		slot := c.globalSlot(d.Name)
+		c.emitShort(OP_DEFINE_GLOBAL, slot)
+		c.emitAt(d.Name, tokenSpan(d.Name), OP_GET_GLOBAL, uint16Operand(slot)...)
+	} else {
+		c.emitShort(OP_SET_LOCAL, slot)
+	}
+	for _, method := range d.Methods {
+		functionType := FT_METHOD
+		if method.Name.Str == "init" {
+			functionType = FT_INITIALIZER
+		}
+		c.compileFunction(method, functionType)
+		c.emitShort(OP_METHOD, c.makeConstant(method.Name.Str))
+	}
+	c.at = d.Name
+	c.emit(OP_POP)
+	if d.Superclass != nil {
+		c.endScope()
+	}
+}
+
+func (c *Compiler) superclassSpan(d *ClassDeclaration) Span {
+	if d.Superclass == nil {
+		return tokenSpan(d.Name)
+	}
+	return d.Superclass.Span()
+}
+
+func (l *Literal) Compile(c *Compiler) {
+	switch l.token.Type {
+	case NIL:
+		c.emit(OP_NIL)
+	case TRUE:
+		c.emit(OP_TRUE)
+	case FALSE:
+		c.emit(OP_FALSE)
+	default:
+		c.at = l.token
+		c.emitConstant(l.token.Content)
+	}
+}
+
+func (g *Grouping) Compile(c *Compiler) {
+	g.expr.Compile(c)
+}
+
+func (u *Unary) Compile(c *Compiler) {
+	u.Expr.Compile(c)
+	c.at = u.Op
+	switch u.Op.Type {
+	case MINUS:
+		c.emitAt(u.Op, u.Span(), OP_NEGATE)
+	case BANG:
+		c.emit(OP_NOT)
+	}
+}
+
+var binaryOpCodes = map[TokenType][]OpCode{
+	PLUS:          {OP_ADD},
+	MINUS:         {OP_SUBTRACT},
+	STAR:          {OP_MULTIPLY},
+	SLASH:         {OP_DIVIDE},
+	LESS:          {OP_LESS},
+	LESS_EQUAL:    {OP_LESS_EQUAL},
+	GREATER:       {OP_GREATER},
+	GREATER_EQUAL: {OP_GREATER_EQUAL},
+	EQUAL_EQUAL:   {OP_EQUAL},
+	BANG_EQUAL:    {OP_EQUAL, OP_NOT},
+}
+
+func (b *Binary) Compile(c *Compiler) {
+	b.Left.Compile(c)
+	b.Right.Compile(c)
+	c.at = b.Op
+	for _, op := range binaryOpCodes[b.Op.Type] {
+		c.emitAt(b.Op, b.Span(), op)
+	}
+}
+
+func (l *Logical) Compile(c *Compiler) {
+	l.left.Compile(c)
+	c.at = l.operator
+	if l.operator.Type == OR {
+		elseJump := c.emitJump(OP_JUMP_IF_FALSE)
+		endJump := c.emitJump(OP_JUMP)
+		c.patchJump(elseJump)
+		c.emit(OP_POP)
+		l.right.Compile(c)
+		c.patchJump(endJump)
+		return
+	}
+	endJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emit(OP_POP)
+	l.right.Compile(c)
+	c.patchJump(endJump)
+}
+
+func (v *Variable) Compile(c *Compiler) {
+	c.at = v.Name
+	c.getVariable(v.Name)
+}
+
+func (a *Assign) Compile(c *Compiler) {
+	a.Value.Compile(c)
+	c.at = a.Name.Name
+	c.setVariable(a.Name.Name)
+}
+
+func (e *Call) Compile(c *Compiler) {
+	op := OP_CALL
+	if get, ok := e.callee.(*Get); ok {
+		// Calls to methods are invoked without binding the method.
+		get.object.Compile(c)
+		c.at = get.name
+		c.emitShortAt(get.name, get.object.Span(), OP_GET_METHOD, c.makeConstant(get.name.Str))
+		op = OP_INVOKE
+	} else {
+		e.callee.Compile(c)
+	}
+	for _, argument := range e.arguments {
+		argument.Compile(c)
+	}
+	if e.tail {
+		// The call replaces the frame of the function returning it.
+		op += OP_TAIL_CALL - OP_CALL
+	}
+	c.at = e.paren
+	c.emitAt(e.paren, e.callee.Span(), op, uint16Operand(len(e.arguments))...)
+}
+
+func (g *Get) Compile(c *Compiler) {
+	g.object.Compile(c)
+	c.at = g.name
+	c.emitShortAt(g.name, g.object.Span(), OP_GET_PROPERTY, c.makeConstant(g.name.Str))
+}
+
+func (s *Set) Compile(c *Compiler) {
+	s.object.Compile(c)
+	// The object is checked before the value is evaluated, so the value's
+	// side effects don't happen when the assignment fails.
+	c.emitAt(s.name, s.object.Span(), OP_CHECK_FIELDS)
+	s.value.Compile(c)
+	c.at = s.name
+	c.emitShortAt(s.name, s.object.Span(), OP_SET_PROPERTY, c.makeConstant(s.name.Str))
+}
+
+func (t *This) Compile(c *Compiler) {
+	c.at = t.keyword
+	c.getVariable(t.keyword)
+}
+
+func (s *Super) Compile(c *Compiler) {
+	c.at = s.keyword
+	c.getVariable(&Token{Type: THIS, Str: "this", Line: s.keyword.Line})
+	c.getVariable(s.keyword)
+	c.emitShortAt(s.method, tokenSpan(s.method), OP_GET_SUPER, c.makeConstant(s.method.Str))
+}
+
+func (l *List) Compile(c *Compiler) {
+	for _, element := range l.elements {
+		element.Compile(c)
+	}
+	if len(l.elements) > math.MaxUint16 {
+		c.error(l.bracket, "Too many elements in list literal.")
+	}
+	c.at = l.bracket
+	c.emitShort(OP_LIST, len(l.elements))
+}
+
+func (i *Interpolation) Compile(c *Compiler) {
+	for _, part := range i.parts {
+		part.Compile(c)
+	}
+	if len(i.parts) > math.MaxUint16 {
+		c.error(i.start, "Too many parts in interpolated string.")
+	}
+	c.at = i.start
+	c.emitShort(OP_INTERPOLATE, len(i.parts))
+}
+
+func (i *Index) Compile(c *Compiler) {
+	i.object.Compile(c)
+	i.index.Compile(c)
+	c.at = i.bracket
+	c.emitAt(i.bracket, i.Span(), OP_GET_INDEX)
+}
+
+func (s *SetIndex) Compile(c *Compiler) {
+	s.object.Compile(c)
+	s.index.Compile(c)
+	s.value.Compile(c)
+	c.at = s.bracket
+	c.emitAt(s.bracket, s.Span(), OP_SET_INDEX)
+}
+
+func (m *Map) Compile(c *Compiler) {
+	for i, key := range m.keys {
+		key.Compile(c)
+		m.values[i].Compile(c)
+	}
+	if len(m.keys) > math.MaxUint16 {
+		c.error(m.brace, "Too many entries in map literal.")
+	}
+	c.at = m.brace
+	c.emitAt(m.brace, m.Span(), OP_MAP, uint16Operand(len(m.keys))...)
+}
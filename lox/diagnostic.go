@@ -0,0 +1,73 @@
+package lox
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// FormatError renders err in the file:line:col form understood by editors,
+// followed by the source line it refers to with a ^~~~ marker under the
+// offending text. Errors joined together are rendered one after the other.
+func FormatError(err error, filename string, source []byte) string {
+	sb := strings.Builder{}
+	formatError(&sb, err, filename, source)
+	return sb.String()
+}
+
+func formatError(sb *strings.Builder, err error, filename string, source []byte) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			formatError(sb, err, filename, source)
+		}
+		return
+	}
+	kind, msg, span := "error", err.Error(), Span{}
+	var trace []CallFrame
+	switch err := err.(type) {
+	case *SyntaxError:
+		msg, span = err.Message, tokenSpan(err.Token)
+	case *ResolveError:
+		msg, span = err.Message, tokenSpan(err.Token)
+	case *RuntimeError:
+		kind, msg, span, trace = "runtime error", err.Message, err.Span, err.Trace
+	}
+	if span.Start == nil || span.Start.Line == 0 || span.Start.Offset > len(source) {
+		sb.WriteString(err.Error())
+		sb.WriteByte('\n')
+		return
+	}
+	fmt.Fprintf(sb, "%s:%d:%d: %s: %s\n", filename, span.Start.Line, span.Start.Column, kind, msg)
+
+	start := span.Start.Offset
+	lineStart := bytes.LastIndexByte(source[:start], '\n') + 1
+	lineEnd := len(source)
+	if i := bytes.IndexByte(source[start:], '\n'); i >= 0 {
+		lineEnd = start + i
+	}
+	line := bytes.TrimRight(source[lineStart:lineEnd], "\r")
+	sb.Write(line)
+	sb.WriteByte('\n')
+
+	// Keep tabs so the marker lines up with the text above it.
+	for _, ch := range string(source[lineStart:start]) {
+		if ch == '\t' {
+			sb.WriteByte('\t')
+		} else {
+			sb.WriteByte(' ')
+		}
+	}
+	sb.WriteByte('^')
+	end := start
+	if span.End != nil {
+		end = min(max(span.End.End(), start), lineStart+len(line))
+	}
+	if width := utf8.RuneCount(source[start:end]); width > 1 {
+		sb.WriteString(strings.Repeat("~", width-1))
+	}
+	sb.WriteByte('\n')
+	if trace != nil {
+		formatTrace(sb, span.Start.Line, trace)
+	}
+}
@@ -0,0 +1,26 @@
+package lox
+
+import "testing"
+
+func TestFormatError(t *testing.T) {
+	source := []byte("var a = 1;\nprint a +\n\t(a - \"b\");")
+	err := NewInterpreter().Run(source)
+	want := "test.lox:3:3: runtime error: Operands must be numbers.\n" +
+		"\t(a - \"b\");\n" +
+		"\t ^~~~~~~\n"
+	if got := FormatError(err, "test.lox", source); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	source = []byte("print ;\nvar = 2;")
+	err = NewInterpreter().Run(source)
+	want = "test.lox:1:7: error: Expected expression.\n" +
+		"print ;\n" +
+		"      ^\n" +
+		"test.lox:2:5: error: Expect variable name.\n" +
+		"var = 2;\n" +
+		"    ^\n"
+	if got := FormatError(err, "test.lox", source); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
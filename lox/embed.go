@@ -0,0 +1,76 @@
+package lox
+
+import "fmt"
+
+// SetGlobal converts a Go value with ToLox and stores it in a global
+// variable, defining the variable if needed.
+func (in *Interpreter) SetGlobal(name string, value any) error {
+	converted, err := ToLox(value)
+	if err != nil {
+		return err
+	}
+	in.globals.Define(name, converted)
+	return nil
+}
+
+// Call calls a Lox function, class or native function with arguments
+// converted from Go values by ToLox. The result is returned as a Lox value,
+// which FromLox can convert back. Errors raised while the callee runs are
+// returned as a *RuntimeError.
+func (in *Interpreter) Call(callee any, args ...any) (result any, err error) {
+	function, ok := callee.(LoxCallable)
+	if !ok {
+		return nil, fmt.Errorf("lox: can't call %s", typeName(callee))
+	}
+	arguments := make([]Value, len(args))
+	for i, arg := range args {
+		converted, err := ToLox(arg)
+		if err != nil {
+			return nil, fmt.Errorf("lox: argument %d: %w", i+1, err)
+		}
+		arguments[i] = ValueOf(converted)
+	}
+	if native, ok := function.(*NativeFunction); ok {
+		result, err := native.call(in, arguments)
+		return result.Any(), err
+	}
+	if len(arguments) != function.Arity() {
+		return nil, &RuntimeError{Message: fmt.Sprintf("Expected %d arguments but got %d.", function.Arity(), len(arguments))}
+	}
+	defer in.recoverRuntimeError(&err, in.save())
+	in.pushFrame(function, nil)
+	result = function.Call(in, arguments).Any()
+	in.popFrame()
+	return result, nil
+}
+
+// CallGlobal calls the function or class stored in a global variable.
+func (in *Interpreter) CallGlobal(name string, args ...any) (any, error) {
+	callee, found := in.Global(name)
+	if !found {
+		return nil, &RuntimeError{Message: fmt.Sprintf("Undefined variable '%s'.", name)}
+	}
+	return in.Call(callee, args...)
+}
+
+// Invoke calls a method of an instance, or a function stored in one of its
+// fields, the same way instance.name(args) does in Lox.
+func (in *Interpreter) Invoke(instance *LoxInstance, name string, args ...any) (any, error) {
+	if field, found := instance.fields[name]; found {
+		return in.Call(field.Any(), args...)
+	}
+	method := instance.class.FindMethod(name)
+	if method == nil {
+		return nil, &RuntimeError{Message: "Undefined property '" + name + "'."}
+	}
+	return in.Call(method.Bind(instance), args...)
+}
+
+// Callback turns a Lox function into a Go function. Native functions can keep
+// the callbacks they are given and call them later, for example when an event
+// happens in the host.
+func (in *Interpreter) Callback(function LoxCallable) func(args ...any) (any, error) {
+	return func(args ...any) (any, error) {
+		return in.Call(function, args...)
+	}
+}
@@ -0,0 +1,94 @@
+package lox
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCallFromGo(t *testing.T) {
+	in := NewInterpreter()
+	handlers := map[string]func(args ...any) (any, error){}
+	in.DefineNative(&NativeFunction{
+		Name:   "on",
+		Params: []ParamType{PT_STRING, PT_CALLABLE},
+		Fn: func(in *Interpreter, arguments []any) (any, error) {
+			handlers[arguments[0].(string)] = in.Callback(arguments[1].(LoxCallable))
+			return nil, nil
+		},
+	})
+	err := in.Run([]byte(`
+		fun add(a, b) { return a + b; }
+		class Greeter {
+			init(greeting) { this.greeting = greeting; }
+			greet(name) { return this.greeting + ", " + name + "!"; }
+		}
+		var count = 0;
+		fun tick(n) { count = count + n; return count; }
+		on("tick", tick);
+		on("fail", add);
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := in.CallGlobal("add", 2, 3.5)
+	if err != nil || result != 5.5 {
+		t.Errorf("add returned %v, %v", result, err)
+	}
+
+	greeter, err := in.CallGlobal("Greeter", "Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = in.Invoke(greeter.(*LoxInstance), "greet", "Go")
+	if err != nil || result != "Hello, Go!" {
+		t.Errorf("greet returned %v, %v", result, err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		if _, err := handlers["tick"](i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if count, _ := in.Global("count"); count != 6.0 {
+		t.Errorf("count is %v after the callbacks", count)
+	}
+
+	var runtimeError *RuntimeError
+	_, err = handlers["fail"]("a", 1)
+	if !errors.As(err, &runtimeError) || runtimeError.Error() != "Operands must be two numbers or two strings.\n[line 2] in add()" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := in.CallGlobal("add", 1); err == nil || err.Error() != "Expected 2 arguments but got 1." {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := in.Invoke(greeter.(*LoxInstance), "wave"); err == nil || err.Error() != "Undefined property 'wave'." {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := in.Call(1.0); err == nil || err.Error() != "lox: can't call number" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestTraceThroughNativeCallback(t *testing.T) {
+	in := NewInterpreter()
+	in.DefineNative(&NativeFunction{
+		Name:   "apply",
+		Params: []ParamType{PT_CALLABLE, PT_ANY},
+		Fn: func(in *Interpreter, arguments []any) (any, error) {
+			return in.Call(arguments[0], arguments[1])
+		},
+	})
+	err := in.Run([]byte("fun half(x) {\n  return x / 2;\n}\nfun run() {\n  return apply(half, \"ten\");\n}\nprint run();"))
+	want := []string{
+		"Operands must be numbers.",
+		"[line 2] in half()",
+		"[native] in apply()",
+		"[line 5] in run()",
+		"[line 7] in script",
+	}
+	if err == nil || err.Error() != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", err, strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,106 @@
+package lox
+
+import "fmt"
+
+// Frame holds the local variables of a function call, in the slots the
+// resolver gave them. Variables captured by a closure live in a Cell in their
+// slot, and the cells captured by the called function are in upvalues.
+type Frame struct {
+	slots    []Value
+	upvalues []*Cell
+}
+
+// Cell boxes a captured variable so the function declaring it and the
+// closures capturing it share it.
+type Cell struct {
+	Value Value
+}
+
+type BindingKind int
+
+const (
+	BK_GLOBAL BindingKind = iota
+	BK_LOCAL
+	BK_CELL
+	BK_UPVALUE
+)
+
+// Binding tells where a variable lives: at Index in the globals, in the slots
+// of the current frame, directly or in a cell, or in its upvalues.
+type Binding struct {
+	Kind  BindingKind
+	Index int
+}
+
+// undefined fills the slots of global variables that have been referred to
+// but not defined yet.
+type undefinedValue struct{}
+
+var undefined = objectValue(undefinedValue{})
+
+// Globals holds the global variables of an interpreter. The resolver gives
+// each global name it comes across a slot, so globals are read by index when
+// the program runs.
+type Globals struct {
+	slots  map[string]int
+	names  []string
+	values []Value
+}
+
+func NewGlobals() *Globals {
+	return &Globals{slots: make(map[string]int)}
+}
+
+// slot returns the slot of a global variable, adding one if needed.
+func (g *Globals) slot(name string) int {
+	if slot, found := g.slots[name]; found {
+		return slot
+	}
+	slot := len(g.values)
+	g.slots[name] = slot
+	g.names = append(g.names, name)
+	g.values = append(g.values, undefined)
+	return slot
+}
+
+// Define sets a global variable, defining it if needed.
+func (g *Globals) Define(name string, value any) {
+	g.values[g.slot(name)] = ValueOf(value)
+}
+
+// Lookup returns the value of a global variable.
+func (g *Globals) Lookup(name string) (any, bool) {
+	slot, found := g.slots[name]
+	if !found || g.values[slot] == undefined {
+		return nil, false
+	}
+	return g.values[slot].Any(), true
+}
+
+// Names returns the names of the defined global variables.
+func (g *Globals) Names() []string {
+	names := make([]string, 0, len(g.names))
+	for slot, name := range g.names {
+		if g.values[slot] != undefined {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// get reads a global variable, which is reported as undefined at the token
+// if it hasn't been defined.
+func (g *Globals) get(slot int, token *Token) Value {
+	value := g.values[slot]
+	if value == undefined {
+		runtimeError(token, fmt.Sprintf("Undefined variable '%s'.", g.names[slot]))
+	}
+	return value
+}
+
+func (g *Globals) assign(slot int, token *Token, value Value) {
+	if g.values[slot] == undefined {
+		runtimeError(token, fmt.Sprintf("Undefined variable '%s'.", g.names[slot]))
+	}
+	g.values[slot] = value
+}
@@ -0,0 +1,67 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyntaxError is reported by the tokenizer and the parser.
+type SyntaxError struct {
+	Token   *Token
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return formatStaticError(e.Token, e.Message)
+}
+
+// ResolveError is reported by the resolver for programs that parse but are
+// not valid, such as a return statement at the top level.
+type ResolveError struct {
+	Token   *Token
+	Message string
+}
+
+func (e *ResolveError) Error() string {
+	return formatStaticError(e.Token, e.Message)
+}
+
+// RuntimeError is reported while a program is running. Span covers the
+// expression that failed, which can be wider than Token, and Trace holds the
+// calls that were in progress, outermost first.
+type RuntimeError struct {
+	Token   *Token
+	Message string
+	Span    Span
+	Trace   []CallFrame
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Token == nil {
+		return e.Message
+	}
+	sb := strings.Builder{}
+	sb.WriteString(e.Message)
+	sb.WriteByte('\n')
+	formatTrace(&sb, e.Token.Line, e.Trace)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func formatStaticError(token *Token, msg string) string {
+	switch token.Type {
+	case UNKNOWN:
+		return fmt.Sprintf("[line %d] Error: %s", token.Line, msg)
+	case EOF:
+		return fmt.Sprintf("[line %d] Error at end: %s", token.Line, msg)
+	default:
+		return fmt.Sprintf("[line %d] Error at '%s': %s", token.Line, token.Str, msg)
+	}
+}
+
+func runtimeError(token *Token, msg string) {
+	panic(&RuntimeError{token, msg, tokenSpan(token), nil})
+}
+
+func runtimeErrorSpan(token *Token, span Span, msg string) {
+	panic(&RuntimeError{token, msg, span, nil})
+}
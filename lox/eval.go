@@ -0,0 +1,793 @@
+package lox
+
+import "fmt"
+
+// The tree-walker doesn't recurse in Go. Expressions and statements being
+// evaluated, and Lox functions being called, are tasks on a stack kept by
+// the interpreter, and run lets the task on top take one step at a time. A
+// step either finishes the task or pushes the tasks it has to wait for, and
+// is taken again once they are done. Expressions leave their value on the
+// interpreter's value stack, so a binary operator finds both its operands
+// there. How deep Lox code can recurse is therefore limited by MaxDepth
+// rather than by the size of the Go stack.
+
+// task is an expression, a statement or a call on the task stack.
+type task struct {
+	node node
+	// state tells a node with several steps which one comes next, and index
+	// counts the statements of a block or the arguments of a call already
+	// pushed.
+	state int
+	index int
+}
+
+type node interface {
+	// step makes progress on the task for the node, which is on top of the
+	// task stack. It must be done with t before pushing any task, since
+	// that can move the stack.
+	step(in *Interpreter, t *task)
+}
+
+// activation is a Lox function being called.
+type activation struct {
+	// prev is the frame of the caller, restored when the function returns.
+	prev     Frame
+	receiver *LoxInstance
+	// task is the index of the function's task.
+	task int
+}
+
+// run steps through tasks until only depth of them are left.
+func (in *Interpreter) run(depth int) {
+	for len(in.tasks) > depth {
+		t := &in.tasks[len(in.tasks)-1]
+		t.node.step(in, t)
+	}
+}
+
+// evaluate pushes the task for an expression, which pushes its value when
+// it's done. Literals and variables are evaluated right away instead, and
+// evaluate reports whether that happened, so the step asking for the value
+// can go on using it rather than return and wait.
+func (in *Interpreter) evaluate(expr Expr) bool {
+	switch expr := expr.(type) {
+	case *Literal:
+		in.push(expr.value())
+	case *Variable:
+		in.push(in.lookUpVariable(expr.binding, expr.Name))
+	default:
+		in.tasks = append(in.tasks, task{node: expr})
+		return false
+	}
+	return true
+}
+
+// execute pushes the task for a statement.
+func (in *Interpreter) execute(statement Stmt) {
+	in.tasks = append(in.tasks, task{node: statement})
+}
+
+// finish removes the task on top of the stack, which is done.
+func (in *Interpreter) finish() {
+	in.tasks = in.tasks[:len(in.tasks)-1]
+}
+
+// done finishes the task of an expression with its value.
+func (in *Interpreter) done(value Value) {
+	in.finish()
+	in.push(value)
+}
+
+func (in *Interpreter) push(value Value) {
+	in.values = append(in.values, value)
+}
+
+func (in *Interpreter) pop() Value {
+	value := in.values[len(in.values)-1]
+	in.values = in.values[:len(in.values)-1]
+	return value
+}
+
+func (in *Interpreter) peek() Value {
+	return in.values[len(in.values)-1]
+}
+
+// enter starts a call to a Lox function. The function's task pushes its
+// result when the call returns.
+func (in *Interpreter) enter(f *LoxFunction, receiver *LoxInstance, arguments []Value) {
+	in.calls = append(in.calls, activation{prev: in.frame, receiver: receiver, task: len(in.tasks)})
+	in.frame = in.newFrame(f.declaration.size, f.upvalues)
+	if receiver != nil {
+		in.define(f.declaration.this, objectValue(receiver))
+	}
+	for i, argument := range arguments {
+		in.define(f.declaration.params[i], argument)
+	}
+	in.tasks = append(in.tasks, task{node: f})
+}
+
+// step runs the statements of the function's body one after the other, and
+// then returns from the call. A return statement skips the rest of the body
+// by setting state.
+func (f *LoxFunction) step(in *Interpreter, t *task) {
+	if body := f.declaration.Body; t.state == 0 && t.index < len(body) {
+		t.index++
+		in.execute(body[t.index-1])
+		return
+	}
+	result := nilValue
+	if t.state != 0 {
+		result, in.returnValue = in.returnValue, nilValue
+	}
+	call := in.calls[len(in.calls)-1]
+	in.calls = in.calls[:len(in.calls)-1]
+	in.leaveFrame(call.prev)
+	in.finish()
+	if f.isInitializer {
+		result = objectValue(call.receiver)
+	} else if tail := in.tailCall; tail.function != nil {
+		// The function returned a call to another one, which runs in its
+		// place rather than on top of it.
+		in.tailCall.function = nil
+		in.replaceFrame(tail.function, tail.paren)
+		in.enter(tail.function, tail.receiver, tail.arguments)
+		return
+	}
+	in.push(result)
+}
+
+func (l *Literal) step(in *Interpreter, t *task) {
+	in.done(l.value())
+}
+
+func (l *Literal) value() Value {
+	switch l.token.Type {
+	case NIL:
+		return nilValue
+	case TRUE:
+		return trueValue
+	case FALSE:
+		return falseValue
+	case NUMBER:
+		return numberValue(l.token.Content.(float64))
+	case STRING:
+		return ValueOf(l.token.Content)
+	}
+	runtimeError(l.token, "unknow type")
+	return nilValue
+}
+
+func (l *Logical) step(in *Interpreter, t *task) {
+	if t.state == 0 {
+		t.state = 1
+		if !in.evaluate(l.left) {
+			return
+		}
+	}
+	left := in.peek()
+	switch l.operator.Type {
+	case OR:
+		if left.isTruthy() {
+			in.finish()
+			return
+		}
+	case AND:
+		if !left.isTruthy() {
+			in.finish()
+			return
+		}
+	default:
+		runtimeError(l.operator, "unknow operator")
+	}
+	in.pop()
+	in.finish()
+	in.evaluate(l.right)
+}
+
+func (g *Grouping) step(in *Interpreter, t *task) {
+	in.finish()
+	in.evaluate(g.expr)
+}
+
+func (u *Unary) step(in *Interpreter, t *task) {
+	if t.state == 0 {
+		t.state = 1
+		if !in.evaluate(u.Expr) {
+			return
+		}
+	}
+	value := in.pop()
+	switch u.Op.Type {
+	case MINUS:
+		if value.kind == VK_NUMBER {
+			in.done(numberValue(-value.number))
+			return
+		}
+		runtimeErrorSpan(u.Op, u.Span(), "Operand must be a number.")
+	case BANG:
+		in.done(boolValue(!value.isTruthy()))
+		return
+	}
+	runtimeError(u.Op, "invalid op")
+}
+
+func (b *Binary) step(in *Interpreter, t *task) {
+	if t.state == 0 {
+		t.state = 1
+		if !in.evaluate(b.Left) {
+			return
+		}
+	}
+	if t.state == 1 {
+		t.state = 2
+		if !in.evaluate(b.Right) {
+			return
+		}
+	}
+	right := in.pop()
+	left := in.pop()
+	in.done(b.apply(left, right))
+}
+
+// apply applies the operator to the values of the operands.
+func (b *Binary) apply(left, right Value) Value {
+	switch b.Op.Type {
+	case EQUAL_EQUAL:
+		return boolValue(left.equals(right))
+	case BANG_EQUAL:
+		return boolValue(!left.equals(right))
+	case PLUS:
+		if left.kind == VK_STRING && right.kind == VK_STRING {
+			return concat(left, right)
+		}
+		if left.kind != VK_NUMBER || right.kind != VK_NUMBER {
+			runtimeErrorSpan(b.Op, b.Span(), "Operands must be two numbers or two strings.")
+		}
+		return numberValue(left.number + right.number)
+	}
+	if left.kind != VK_NUMBER || right.kind != VK_NUMBER {
+		runtimeErrorSpan(b.Op, b.Span(), "Operands must be numbers.")
+	}
+	switch b.Op.Type {
+	case MINUS:
+		return numberValue(left.number - right.number)
+	case STAR:
+		return numberValue(left.number * right.number)
+	case SLASH:
+		return numberValue(left.number / right.number)
+	case LESS:
+		return boolValue(left.number < right.number)
+	case GREATER:
+		return boolValue(left.number > right.number)
+	case LESS_EQUAL:
+		return boolValue(left.number <= right.number)
+	case GREATER_EQUAL:
+		return boolValue(left.number >= right.number)
+	}
+	runtimeError(b.Op, "not implemented")
+	return nilValue
+}
+
+func (s *PrintStatement) step(in *Interpreter, t *task) {
+	if t.state == 0 {
+		t.state = 1
+		if !in.evaluate(s.Value) {
+			return
+		}
+	}
+	fmt.Fprintln(in.Stdout, in.pop())
+	in.finish()
+}
+
+func (s *ExpressionStatement) step(in *Interpreter, t *task) {
+	if t.state == 0 {
+		t.state = 1
+		if !in.evaluate(s.Expr) {
+			return
+		}
+	}
+	in.pop()
+	in.finish()
+}
+
+func (s *VarStatement) step(in *Interpreter, t *task) {
+	if t.state == 0 && s.Initializer != nil {
+		t.state = 1
+		if !in.evaluate(s.Initializer) {
+			return
+		}
+	}
+	value := nilValue
+	if s.Initializer != nil {
+		value = in.pop()
+	}
+	in.define(s.binding, value)
+	in.finish()
+}
+
+func (s *IfStatement) step(in *Interpreter, t *task) {
+	if t.state == 0 {
+		t.state = 1
+		if !in.evaluate(s.Condition) {
+			return
+		}
+	}
+	in.finish()
+	if in.pop().isTruthy() {
+		in.execute(s.ThenBranch)
+	} else if s.ElseBranch != nil {
+		in.execute(s.ElseBranch)
+	}
+}
+
+// step checks the condition and runs the body, then evaluates the
+// increment before going round again. Continue statements set state to 2
+// to skip to the increment.
+func (w *WhileStatement) step(in *Interpreter, t *task) {
+	if t.state == 2 {
+		t.state = 3
+		if w.Increment != nil && !in.evaluate(w.Increment) {
+			return
+		}
+	}
+	if t.state == 3 {
+		if w.Increment != nil {
+			in.pop()
+		}
+		t.state = 0
+	}
+	if t.state == 0 {
+		t.state = 1
+		if !in.evaluate(w.Condition) {
+			return
+		}
+	}
+	if !in.pop().isTruthy() {
+		in.finish()
+		return
+	}
+	t.state = 2
+	in.execute(w.Body)
+}
+
+// step drops the tasks of the loop's body, and the loop's own.
+func (s *BreakStatement) step(in *Interpreter, t *task) {
+	in.tasks = in.tasks[:in.loopTask(s.loop)]
+}
+
+// step drops the tasks of the loop's body, which leaves the loop to run the
+// increment and go round again.
+func (s *ContinueStatement) step(in *Interpreter, t *task) {
+	loop := in.loopTask(s.loop)
+	in.tasks = in.tasks[:loop+1]
+	in.tasks[loop].state = 2
+}
+
+// loopTask returns the index of the task of a loop around the statement
+// running. Statements leave nothing on the value stack once done, so the
+// tasks above the loop's can be dropped as they are.
+func (in *Interpreter) loopTask(loop *WhileStatement) int {
+	i := len(in.tasks) - 1
+	for in.tasks[i].node != node(loop) {
+		i--
+	}
+	return i
+}
+
+func (b *Block) step(in *Interpreter, t *task) {
+	if t.index == len(b.Statements) {
+		in.finish()
+		return
+	}
+	t.index++
+	in.execute(b.Statements[t.index-1])
+}
+
+func (f *FunctionDeclaration) step(in *Interpreter, t *task) {
+	// The function is defined before capturing so it can refer to itself.
+	in.define(f.binding, nilValue)
+	function := &LoxFunction{declaration: f, upvalues: in.capture(f)}
+	in.assignVariable(f.binding, f.Name, objectValue(function))
+	in.finish()
+}
+
+// step evaluates the value to return, then drops the tasks of the function
+// returning down to its own and tells it to return.
+func (r *ReturnStatement) step(in *Interpreter, t *task) {
+	if t.state == 0 {
+		t.state = 1
+		if r.value == nil {
+			in.push(nilValue)
+		} else if !in.evaluate(r.value) {
+			return
+		}
+	}
+	in.returnValue = in.pop()
+	call := in.calls[len(in.calls)-1]
+	in.tasks = in.tasks[:call.task+1]
+	in.tasks[call.task].state = 1
+}
+
+func (c *ClassDeclaration) step(in *Interpreter, t *task) {
+	if t.state == 0 && c.Superclass != nil {
+		t.state = 1
+		if !in.evaluate(c.Superclass) {
+			return
+		}
+	}
+	var superclass *LoxClass
+	if c.Superclass != nil {
+		var ok bool
+		superclass, ok = in.pop().ref.(*LoxClass)
+		if !ok {
+			runtimeErrorSpan(c.Name, c.Superclass.Span(), "Superclass must be a class.")
+		}
+	}
+	in.define(c.binding, nilValue)
+	if c.Superclass != nil {
+		in.define(c.super, objectValue(superclass))
+	}
+	class := newClass(c.Name.Str, superclass)
+	for _, method := range c.Methods {
+		class.methods[method.Name.Str] = &LoxFunction{
+			declaration:   method,
+			upvalues:      in.capture(method),
+			isInitializer: method.Name.Str == "init",
+			class:         class,
+		}
+	}
+	in.assignVariable(c.binding, c.Name, objectValue(class))
+	in.finish()
+}
+
+func (v *Variable) step(in *Interpreter, t *task) {
+	in.done(in.lookUpVariable(v.binding, v.Name))
+}
+
+func (a *Assign) step(in *Interpreter, t *task) {
+	if t.state == 0 {
+		t.state = 1
+		if !in.evaluate(a.Value) {
+			return
+		}
+	}
+	// The value stays on the stack as the value of the assignment.
+	in.assignVariable(a.Name.binding, a.Name.Name, in.peek())
+	in.finish()
+}
+
+// step evaluates the callee and the arguments, then calls the callee. The
+// callee is kept on the value stack below the arguments, along with the
+// instance a method is called on or nil, so that methods are called
+// without binding them first.
+func (c *Call) step(in *Interpreter, t *task) {
+	if t.state == 3 {
+		// The Lox function called has returned.
+		in.popFrame()
+		c.finish(in, in.pop())
+		return
+	}
+	if t.state == 0 {
+		t.state = 1
+		switch callee := c.callee.(type) {
+		case *Get:
+			if !in.evaluate(callee.object) {
+				return
+			}
+		case *Super:
+			superclass, object := callee.lookUp(in)
+			method := c.cache.lookUp(superclass, callee.method.Str)
+			if method == nil {
+				runtimeError(callee.method, "Undefined property '"+callee.method.Str+"'.")
+			}
+			in.push(objectValue(method))
+			in.push(objectValue(object))
+			t.state = 2
+		default:
+			if !in.evaluate(c.callee) {
+				return
+			}
+		}
+	}
+	if t.state == 1 {
+		t.state = 2
+		if get, ok := c.callee.(*Get); ok {
+			c.lookUpMethod(in, get, in.pop())
+		} else {
+			in.push(nilValue)
+		}
+	}
+	for t.index < len(c.arguments) {
+		t.index++
+		if !in.evaluate(c.arguments[t.index-1]) {
+			return
+		}
+	}
+	t.state = 3
+	c.call(in)
+}
+
+// lookUpMethod pushes the method of an instance and the instance, or the
+// value of any other property and nil.
+func (c *Call) lookUpMethod(in *Interpreter, get *Get, object Value) {
+	instance, ok := object.ref.(*LoxInstance)
+	if !ok {
+		in.push(get.property(in, object))
+		in.push(nilValue)
+		return
+	}
+	if field, found := instance.fields[get.name.Str]; found {
+		in.push(field)
+		in.push(nilValue)
+		return
+	}
+	method := c.cache.lookUp(instance.class, get.name.Str)
+	if method == nil {
+		runtimeError(get.name, "Undefined property '"+get.name.Str+"'.")
+	}
+	in.push(objectValue(method))
+	in.push(object)
+}
+
+// call calls the callee with the arguments on the value stack. Lox functions
+// are entered and run by the evaluator; other callees are called right
+// away.
+func (c *Call) call(in *Interpreter) {
+	top := len(in.values) - len(c.arguments)
+	callee, receiver, arguments := in.values[top-2], in.values[top-1], in.values[top:]
+	if receiver.kind != VK_NIL {
+		method, instance := callee.ref.(Method), receiver.ref.(*LoxInstance)
+		if len(c.arguments) != method.Arity() {
+			runtimeErrorSpan(c.paren, c.Span(), fmt.Sprintf("Expected %d arguments but got %d.", method.Arity(), len(c.arguments)))
+		}
+		if function, ok := method.(*LoxFunction); ok {
+			c.enter(in, function, instance, arguments)
+			return
+		}
+		in.pushFrame(method, c.paren)
+		result := method.callMethod(in, instance, arguments)
+		in.popFrame()
+		c.finish(in, result)
+		return
+	}
+
+	switch function := callee.ref.(type) {
+	case nativeCallable:
+		in.pushFrame(function, c.paren)
+		result, err := function.call(in, arguments)
+		in.popFrame()
+		if runtimeError, ok := err.(*RuntimeError); ok {
+			// Raised by Lox code the native called back into, which already
+			// recorded where it happened.
+			panic(runtimeError)
+		} else if err != nil {
+			runtimeErrorSpan(c.paren, c.Span(), err.Error())
+		}
+		c.finish(in, result)
+	case LoxCallable:
+		if len(c.arguments) != function.Arity() {
+			runtimeErrorSpan(c.paren, c.Span(), fmt.Sprintf("Expected %d arguments but got %d.", function.Arity(), len(c.arguments)))
+		}
+		switch function := function.(type) {
+		case *LoxFunction:
+			c.enter(in, function, function.receiver, arguments)
+			return
+		case *LoxClass:
+			if initializer, ok := function.FindMethod("init").(*LoxFunction); ok {
+				in.pushFrame(function, c.paren)
+				in.enter(initializer, &LoxInstance{function, make(map[string]Value)}, arguments)
+				return
+			}
+		}
+		in.pushFrame(function, c.paren)
+		result := function.Call(in, arguments)
+		in.popFrame()
+		c.finish(in, result)
+	default:
+		runtimeErrorSpan(c.paren, c.callee.Span(), "Can only call functions and classes.")
+	}
+}
+
+// enter starts the call to a Lox function, unless the call is in tail
+// position: it's then left to the function returning to make.
+func (c *Call) enter(in *Interpreter, function *LoxFunction, receiver *LoxInstance, arguments []Value) {
+	if c.tail {
+		in.returnCall(function, receiver, arguments, c.paren)
+		c.finish(in, nilValue)
+		return
+	}
+	in.pushFrame(function, c.paren)
+	in.enter(function, receiver, arguments)
+}
+
+// finish replaces the callee and the arguments on the value stack with the
+// result of the call.
+func (c *Call) finish(in *Interpreter, result Value) {
+	in.values = in.values[:len(in.values)-len(c.arguments)-2]
+	in.done(result)
+}
+
+func (g *Get) step(in *Interpreter, t *task) {
+	if t.state == 0 {
+		t.state = 1
+		if !in.evaluate(g.object) {
+			return
+		}
+	}
+	in.done(g.property(in, in.pop()))
+}
+
+// property reads the property of the evaluated object.
+func (g *Get) property(in *Interpreter, object Value) Value {
+	switch object := object.ref.(type) {
+	case *LoxInstance:
+		if value, found := object.fields[g.name.Str]; found {
+			return value
+		}
+		if method := g.cache.lookUp(object.class, g.name.Str); method != nil {
+			return objectValue(method.Bind(object))
+		}
+		runtimeError(g.name, "Undefined property '"+g.name.Str+"'.")
+	case HostObject:
+		value, err := object.Get(in, g.name.Str)
+		if err != nil {
+			runtimeError(g.name, err.Error())
+		}
+		return ValueOf(value)
+	case builtinObject:
+		if method := object.method(g.name.Str); method != nil {
+			return objectValue(method)
+		}
+		runtimeError(g.name, "Undefined property '"+g.name.Str+"'.")
+	}
+	runtimeErrorSpan(g.name, g.object.Span(), "Only instances have properties.")
+	return nilValue
+}
+
+// step checks the object can have fields before evaluating the value, so
+// the value's side effects don't happen when the assignment fails.
+func (s *Set) step(in *Interpreter, t *task) {
+	if t.state == 0 {
+		t.state = 1
+		if !in.evaluate(s.object) {
+			return
+		}
+	}
+	if t.state == 1 {
+		switch in.peek().ref.(type) {
+		case *LoxInstance, HostObject:
+		default:
+			runtimeErrorSpan(s.name, s.object.Span(), "Only instances have fields.")
+		}
+		t.state = 2
+		if !in.evaluate(s.value) {
+			return
+		}
+	}
+	value := in.pop()
+	switch object := in.pop().ref.(type) {
+	case *LoxInstance:
+		object.Set(s.name, value)
+	case HostObject:
+		if err := object.Set(in, s.name.Str, value.Any()); err != nil {
+			runtimeError(s.name, err.Error())
+		}
+	}
+	in.done(nilValue)
+}
+
+func (t *This) step(in *Interpreter, _ *task) {
+	in.done(in.lookUpVariable(t.binding, t.keyword))
+}
+
+func (s *Super) step(in *Interpreter, t *task) {
+	superclass, object := s.lookUp(in)
+	method := superclass.FindMethod(s.method.Str)
+	if method == nil {
+		runtimeError(s.method, "Undefined property '"+s.method.Str+"'.")
+	}
+	in.done(objectValue(method.Bind(object)))
+}
+
+// lookUp returns the superclass and the instance the method is called on.
+func (s *Super) lookUp(in *Interpreter) (*LoxClass, *LoxInstance) {
+	superclass := in.lookUpVariable(s.binding, s.keyword).ref.(*LoxClass)
+	object := in.lookUpVariable(s.this, s.keyword).ref.(*LoxInstance)
+	return superclass, object
+}
+
+func (l *List) step(in *Interpreter, t *task) {
+	for t.index < len(l.elements) {
+		t.index++
+		if !in.evaluate(l.elements[t.index-1]) {
+			return
+		}
+	}
+	top := len(in.values) - len(l.elements)
+	list := &LoxList{append([]Value(nil), in.values[top:]...)}
+	in.values = in.values[:top]
+	in.done(objectValue(list))
+}
+
+func (i *Interpolation) step(in *Interpreter, t *task) {
+	for t.index < len(i.parts) {
+		t.index++
+		if !in.evaluate(i.parts[t.index-1]) {
+			return
+		}
+	}
+	top := len(in.values) - len(i.parts)
+	value := interpolate(in.values[top:])
+	in.values = in.values[:top]
+	in.done(value)
+}
+
+func (i *Index) step(in *Interpreter, t *task) {
+	if t.state == 0 {
+		t.state = 1
+		if !in.evaluate(i.object) {
+			return
+		}
+	}
+	if t.state == 1 {
+		t.state = 2
+		if !in.evaluate(i.index) {
+			return
+		}
+	}
+	index := in.pop()
+	value, err := getIndex(in.pop(), index)
+	if err != nil {
+		runtimeErrorSpan(i.bracket, i.Span(), err.Error())
+	}
+	in.done(value)
+}
+
+func (s *SetIndex) step(in *Interpreter, t *task) {
+	if t.state == 0 {
+		t.state = 1
+		if !in.evaluate(s.object) {
+			return
+		}
+	}
+	if t.state == 1 {
+		t.state = 2
+		if !in.evaluate(s.index) {
+			return
+		}
+	}
+	if t.state == 2 {
+		t.state = 3
+		if !in.evaluate(s.value) {
+			return
+		}
+	}
+	value := in.pop()
+	index := in.pop()
+	if err := setIndex(in.pop(), index, value); err != nil {
+		runtimeErrorSpan(s.bracket, s.Span(), err.Error())
+	}
+	in.done(value)
+}
+
+// step evaluates the keys and values in the order they are written, then
+// builds the map from them.
+func (m *Map) step(in *Interpreter, t *task) {
+	for t.index < 2*len(m.keys) {
+		t.index++
+		expr := m.keys[(t.index-1)/2]
+		if t.index%2 == 0 {
+			expr = m.values[(t.index-1)/2]
+		}
+		if !in.evaluate(expr) {
+			return
+		}
+	}
+	top := len(in.values) - 2*len(m.keys)
+	object, err := newMapFrom(in.values[top:])
+	if err != nil {
+		runtimeErrorSpan(m.brace, m.Span(), err.Error())
+	}
+	in.values = in.values[:top]
+	in.done(objectValue(object))
+}
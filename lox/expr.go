@@ -0,0 +1,340 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Expr interface {
+	String() string
+	node
+	Resolve(r *Resolver)
+	Compile(c *Compiler)
+	// Optimize returns a simpler expression that evaluates the same way.
+	Optimize() Expr
+	Span() Span
+}
+
+// Span is the range of source text covered by a token or an expression, from
+// the first character of Start to the last character of End.
+type Span struct {
+	Start *Token
+	End   *Token
+}
+
+func tokenSpan(token *Token) Span {
+	return Span{token, token}
+}
+
+type Literal struct {
+	token *Token
+	// span covers the expression a literal made by the optimizer replaced.
+	span Span
+}
+
+func (l *Literal) String() string {
+	switch l.token.Type {
+	case NIL:
+		return "nil"
+	case TRUE:
+		return "true"
+	case FALSE:
+		return "false"
+	case NUMBER:
+		return FloatFormat(l.token.Content.(float64))
+	case STRING:
+		return l.token.Content.(string)
+	}
+	return "?"
+}
+
+func (l *Literal) Span() Span {
+	if l.span.Start != nil {
+		return l.span
+	}
+	return tokenSpan(l.token)
+}
+
+type Grouping struct {
+	token   *Token
+	expr    Expr
+	closing *Token
+}
+
+func (g *Grouping) String() string {
+	return fmt.Sprintf("(group %s)", g.expr.String())
+}
+
+func (g *Grouping) Span() Span {
+	return Span{g.token, g.closing}
+}
+
+type Unary struct {
+	Op   *Token
+	Expr Expr
+}
+
+func (u *Unary) String() string {
+	if u.Op.Type == MINUS {
+		return fmt.Sprintf("(- %s)", u.Expr.String())
+	} else {
+		return fmt.Sprintf("(! %s)", u.Expr.String())
+	}
+}
+
+func (u *Unary) Span() Span {
+	return Span{u.Op, u.Expr.Span().End}
+}
+
+type Binary struct {
+	Op    *Token
+	Left  Expr
+	Right Expr
+}
+
+func (b *Binary) String() string {
+	op := "?"
+	switch b.Op.Type {
+	case PLUS:
+		op = "+"
+	case MINUS:
+		op = "-"
+	case STAR:
+		op = "*"
+	case SLASH:
+		op = "/"
+	case LESS:
+		op = "<"
+	case GREATER:
+		op = ">"
+	case LESS_EQUAL:
+		op = "<="
+	case GREATER_EQUAL:
+		op = ">="
+	case EQUAL_EQUAL:
+		op = "=="
+	case BANG_EQUAL:
+		op = "!="
+	}
+	return fmt.Sprintf("(%s %s %s)", op, b.Left.String(), b.Right.String())
+}
+
+func (b *Binary) Span() Span {
+	return Span{b.Left.Span().Start, b.Right.Span().End}
+}
+
+type Variable struct {
+	Name    *Token
+	binding Binding
+}
+
+func (v *Variable) String() string {
+	return fmt.Sprintf("(var %s)", v.Name.String())
+}
+
+func (v *Variable) Span() Span {
+	return tokenSpan(v.Name)
+}
+
+type Assign struct {
+	Name  *Variable
+	Value Expr
+}
+
+func (a *Assign) String() string {
+	return fmt.Sprintf("(= %s %s)", a.Name.String(), a.Value.String())
+}
+
+func (a *Assign) Span() Span {
+	return Span{a.Name.Name, a.Value.Span().End}
+}
+
+type Logical struct {
+	left     Expr
+	operator *Token
+	right    Expr
+}
+
+func (l *Logical) String() string {
+	return fmt.Sprintf("(%s %s %s)", l.operator.Str, l.left.String(), l.right.String())
+}
+
+func (l *Logical) Span() Span {
+	return Span{l.left.Span().Start, l.right.Span().End}
+}
+
+type Call struct {
+	callee    Expr
+	paren     *Token
+	arguments []Expr
+	// cache holds the method last invoked when the callee is a property or
+	// a superclass method.
+	cache methodCache
+	// tail is set by the resolver when the call is the value of a return
+	// statement, so a Lox function it calls can run in the caller's frame.
+	tail bool
+}
+
+func (c *Call) String() string {
+	sb := strings.Builder{}
+	for _, arg := range c.arguments {
+		sb.WriteString(" ")
+		sb.WriteString(arg.String())
+	}
+	return fmt.Sprintf("(call %s%s)", c.callee, sb.String())
+}
+
+func (c *Call) Span() Span {
+	return Span{c.callee.Span().Start, c.paren}
+}
+
+type Get struct {
+	object Expr
+	name   *Token
+	cache  methodCache
+}
+
+func (g *Get) String() string {
+	return fmt.Sprintf("(get %s %s)", g.object, g.name)
+}
+
+func (g *Get) Span() Span {
+	return Span{g.object.Span().Start, g.name}
+}
+
+type Set struct {
+	object Expr
+	name   *Token
+	value  Expr
+}
+
+func (s *Set) String() string {
+	return fmt.Sprintf("(get %s %s)", s.object, s.name)
+}
+
+func (s *Set) Span() Span {
+	return Span{s.object.Span().Start, s.value.Span().End}
+}
+
+type This struct {
+	keyword *Token
+	binding Binding
+}
+
+func (t *This) String() string {
+	return "(this)"
+}
+
+func (t *This) Span() Span {
+	return tokenSpan(t.keyword)
+}
+
+type Super struct {
+	keyword *Token
+	method  *Token
+	// binding locates the superclass and this the instance.
+	binding Binding
+	this    Binding
+}
+
+func (s *Super) String() string {
+	return fmt.Sprintf("(super %s)", s.method.Str)
+}
+
+func (s *Super) Span() Span {
+	return Span{s.keyword, s.method}
+}
+
+// List is a list literal.
+type List struct {
+	bracket  *Token
+	elements []Expr
+	closing  *Token
+}
+
+func (l *List) String() string {
+	sb := strings.Builder{}
+	for _, element := range l.elements {
+		sb.WriteString(" ")
+		sb.WriteString(element.String())
+	}
+	return fmt.Sprintf("(list%s)", sb.String())
+}
+
+func (l *List) Span() Span {
+	return Span{l.bracket, l.closing}
+}
+
+// Index reads an element of a list or the value of a map for a key. Errors
+// are reported at the closing bracket.
+type Index struct {
+	object  Expr
+	index   Expr
+	bracket *Token
+}
+
+func (i *Index) String() string {
+	return fmt.Sprintf("(index %s %s)", i.object, i.index)
+}
+
+func (i *Index) Span() Span {
+	return Span{i.object.Span().Start, i.bracket}
+}
+
+// SetIndex assigns an element of a list or the value of a map for a key.
+type SetIndex struct {
+	object  Expr
+	index   Expr
+	bracket *Token
+	value   Expr
+}
+
+func (s *SetIndex) String() string {
+	return fmt.Sprintf("(set-index %s %s %s)", s.object, s.index, s.value)
+}
+
+func (s *SetIndex) Span() Span {
+	return Span{s.object.Span().Start, s.value.Span().End}
+}
+
+// Map is a map literal, with the keys and values of its entries in order.
+type Map struct {
+	brace   *Token
+	keys    []Expr
+	values  []Expr
+	closing *Token
+}
+
+func (m *Map) String() string {
+	sb := strings.Builder{}
+	for i, key := range m.keys {
+		fmt.Fprintf(&sb, " %s %s", key, m.values[i])
+	}
+	return fmt.Sprintf("(map%s)", sb.String())
+}
+
+func (m *Map) Span() Span {
+	return Span{m.brace, m.closing}
+}
+
+// Interpolation is a string with embedded expressions. Its parts are the
+// literal text between the expressions and the expressions themselves, in
+// order, and each is converted to a string the way print shows it.
+type Interpolation struct {
+	start *Token
+	parts []Expr
+	end   *Token
+}
+
+func (i *Interpolation) String() string {
+	sb := strings.Builder{}
+	for _, part := range i.parts {
+		sb.WriteString(" ")
+		sb.WriteString(part.String())
+	}
+	return fmt.Sprintf("(interpolate%s)", sb.String())
+}
+
+func (i *Interpolation) Span() Span {
+	return Span{i.start, i.end}
+}
@@ -0,0 +1,291 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// HostObject is implemented by values that come from the host program but
+// take part in property access from Lox like instances do. Get is used for
+// obj.name, including method lookups, which should return a callable bound
+// to the object, and Set for obj.name = value. The error returned by either
+// is reported as a runtime error at the property name. String is used by
+// print.
+type HostObject interface {
+	Get(in *Interpreter, name string) (any, error)
+	Set(in *Interpreter, name string, value any) error
+	String() string
+}
+
+// HostClass is a Go type exposed to Lox as a class by DefineClass.
+type HostClass struct {
+	name        string
+	constructor *NativeFunction
+	fields      map[string][]int
+}
+
+func (c *HostClass) Arity() int {
+	return c.constructor.Arity()
+}
+
+func (c *HostClass) String() string {
+	return c.name
+}
+
+func (c *HostClass) Call(in *Interpreter, arguments []Value) Value {
+	return c.constructor.Call(in, arguments)
+}
+
+func (c *HostClass) call(in *Interpreter, arguments []Value) (Value, error) {
+	return c.constructor.call(in, arguments)
+}
+
+// HostInstance is a Go value of a type exposed by DefineClass. It always
+// holds a pointer, so fields can be assigned and two instances are equal when
+// they hold the same Go value.
+type HostInstance struct {
+	class  *HostClass
+	object any
+}
+
+// Value returns the Go value the instance holds.
+func (i HostInstance) Value() any {
+	return i.object
+}
+
+func (i HostInstance) String() string {
+	if stringer, ok := i.object.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return i.class.name + " instance"
+}
+
+// Get returns an exported field, or an exported method bound to the
+// instance. Both keep their Go names, unless a field has a lox tag.
+func (i HostInstance) Get(in *Interpreter, name string) (any, error) {
+	v := reflect.ValueOf(i.object)
+	if index, ok := i.class.fields[name]; ok {
+		return in.hostValue(v.Elem().FieldByIndex(index))
+	}
+	if method := v.MethodByName(name); method.IsValid() {
+		return in.nativeMethod(name, method), nil
+	}
+	return nil, fmt.Errorf("Undefined property '%s'.", name)
+}
+
+func (i HostInstance) Set(in *Interpreter, name string, value any) error {
+	index, ok := i.class.fields[name]
+	if !ok {
+		return fmt.Errorf("Undefined field '%s'.", name)
+	}
+	field := reflect.ValueOf(i.object).Elem().FieldByIndex(index)
+	converted, err := in.goValue(value, field.Type(), "field '"+name+"'")
+	if err != nil {
+		return err
+	}
+	field.Set(converted)
+	return nil
+}
+
+// members returns the names of the fields and methods, for completion.
+func (i HostInstance) members() []string {
+	var names []string
+	for name := range i.class.fields {
+		names = append(names, name)
+	}
+	t := reflect.TypeOf(i.object)
+	for j := 0; j < t.NumMethod(); j++ {
+		names = append(names, t.Method(j).Name)
+	}
+	return names
+}
+
+// DefineClass exposes a Go type to Lox as a global class. The constructor is
+// a Go function returning a value of the type, optionally followed by an
+// error, and runs when the class is called with its arguments converted from
+// Lox values. The instances have the exported fields of the type, which can
+// be read and assigned, and its exported methods. Values of the type returned
+// to Lox by other Go methods and natives become instances of the class too.
+func (in *Interpreter) DefineClass(name string, constructor any) error {
+	fn := reflect.ValueOf(constructor)
+	if fn.Kind() != reflect.Func || fn.Type().NumOut() == 0 {
+		return fmt.Errorf("lox: constructor for class %s must be a function returning the new value", name)
+	}
+	t := fn.Type().Out(0)
+	elem := t
+	if t.Kind() == reflect.Pointer {
+		elem = t.Elem()
+	}
+	class := &HostClass{name: name, fields: make(map[string][]int)}
+	if elem.Kind() == reflect.Struct {
+		for _, field := range structFields(elem) {
+			class.fields[field.name] = field.index
+		}
+	}
+	class.constructor = in.nativeMethod(name, fn)
+	in.hostClasses[t] = class
+	if t.Kind() != reflect.Pointer {
+		in.hostClasses[reflect.PointerTo(t)] = class
+	}
+	in.globals.Define(name, class)
+	return nil
+}
+
+// hostValue converts a Go value to a Lox value, wrapping the values of types
+// exposed by DefineClass in a HostInstance.
+func (in *Interpreter) hostValue(v reflect.Value) (any, error) {
+	if class, ok := in.hostClasses[v.Type()]; ok {
+		if v.Kind() != reflect.Pointer {
+			pointer := reflect.New(v.Type())
+			pointer.Elem().Set(v)
+			v = pointer
+		} else if v.IsNil() {
+			return nil, nil
+		}
+		return HostInstance{class, v.Interface()}, nil
+	}
+	return ToLox(v.Interface())
+}
+
+// goValue converts a Lox value for a Go parameter or field of type t. On top
+// of what FromLox does, host instances are unwrapped and Lox functions
+// become Go functions that call back into the interpreter.
+func (in *Interpreter) goValue(value any, t reflect.Type, path string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	if class, ok := in.hostClasses[t]; ok && value != nil {
+		host, ok := value.(HostInstance)
+		if !ok || host.class != class {
+			return v, fmt.Errorf("Expected %s instance as %s but got %s.", class.name, path, typeName(value))
+		}
+	}
+	if host, ok := value.(HostInstance); ok {
+		object := reflect.ValueOf(host.object)
+		if object.Type().AssignableTo(t) {
+			v.Set(object)
+			return v, nil
+		}
+		if object.Elem().Type().AssignableTo(t) {
+			v.Set(object.Elem())
+			return v, nil
+		}
+		return v, fmt.Errorf("Expected %s as %s but got %s instance.", t, path, host.class.name)
+	}
+	if function, ok := value.(LoxCallable); ok && t.Kind() == reflect.Func {
+		v.Set(reflect.MakeFunc(t, in.goCallback(function, t)))
+		return v, nil
+	}
+	if err := fromLox(value, v, path); err != nil {
+		return v, errors.New(strings.TrimPrefix(err.Error(), "lox: "))
+	}
+	return v, nil
+}
+
+var errorType = reflect.TypeFor[error]()
+
+// goCallback implements a Go function of type t that calls a Lox function.
+// Failures are returned when the Go function has an error result, and raised
+// as runtime errors otherwise.
+func (in *Interpreter) goCallback(function LoxCallable, t reflect.Type) func([]reflect.Value) []reflect.Value {
+	returnsError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+	return func(args []reflect.Value) []reflect.Value {
+		results := make([]reflect.Value, t.NumOut())
+		for i := range results {
+			results[i] = reflect.Zero(t.Out(i))
+		}
+		fail := func(err error) []reflect.Value {
+			if !returnsError {
+				if runtimeError, ok := err.(*RuntimeError); ok {
+					panic(runtimeError)
+				}
+				runtimeError(nil, err.Error())
+			}
+			results[len(results)-1] = reflect.ValueOf(&err).Elem()
+			return results
+		}
+
+		arguments := make([]any, len(args))
+		for i, arg := range args {
+			converted, err := in.hostValue(arg)
+			if err != nil {
+				return fail(err)
+			}
+			arguments[i] = converted
+		}
+		result, err := in.Call(function, arguments...)
+		if err != nil {
+			return fail(err)
+		}
+		if len(results) > 0 && t.Out(0) != errorType {
+			converted, err := in.goValue(result, t.Out(0), "result")
+			if err != nil {
+				return fail(err)
+			}
+			results[0] = converted
+		}
+		return results
+	}
+}
+
+// nativeMethod wraps a Go function or bound method as a native function.
+// Parameters of basic types are checked like those of any native function,
+// the arguments are converted with goValue, and a trailing error result is
+// reported as a runtime error.
+func (in *Interpreter) nativeMethod(name string, fn reflect.Value) *NativeFunction {
+	t := fn.Type()
+	params := make([]ParamType, t.NumIn())
+	for i := range params {
+		param := t.In(i)
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			param = param.Elem()
+		}
+		params[i] = paramTypeOf(param)
+	}
+	return &NativeFunction{
+		Name:     name,
+		Params:   params,
+		Variadic: t.IsVariadic(),
+		Fn: func(in *Interpreter, arguments []any) (any, error) {
+			args := make([]reflect.Value, len(arguments))
+			for i, argument := range arguments {
+				param := t.In(min(i, t.NumIn()-1))
+				if t.IsVariadic() && i >= t.NumIn()-1 {
+					param = param.Elem()
+				}
+				arg, err := in.goValue(argument, param, fmt.Sprintf("argument %d to '%s'", i+1, name))
+				if err != nil {
+					return nil, err
+				}
+				args[i] = arg
+			}
+			results := fn.Call(args)
+			if n := len(results); n > 0 && t.Out(n-1) == errorType {
+				if err, _ := results[n-1].Interface().(error); err != nil {
+					return nil, err
+				}
+				results = results[:n-1]
+			}
+			if len(results) == 0 {
+				return nil, nil
+			}
+			return in.hostValue(results[0])
+		},
+	}
+}
+
+func paramTypeOf(t reflect.Type) ParamType {
+	switch t.Kind() {
+	case reflect.Bool:
+		return PT_BOOL
+	case reflect.String:
+		return PT_STRING
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return PT_NUMBER
+	case reflect.Func:
+		return PT_CALLABLE
+	}
+	return PT_ANY
+}
@@ -0,0 +1,90 @@
+package lox
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type point struct {
+	X, Y  float64
+	Label string `lox:"label"`
+}
+
+func (p *point) Add(other *point) *point {
+	return &point{X: p.X + other.X, Y: p.Y + other.Y}
+}
+
+func (p *point) Scale(f func(float64) float64) {
+	p.X, p.Y = f(p.X), f(p.Y)
+}
+
+func (p *point) Check() error {
+	if p.X < 0 {
+		return errors.New("Negative coordinate.")
+	}
+	return nil
+}
+
+func (p *point) String() string {
+	return fmt.Sprintf("(%g, %g)", p.X, p.Y)
+}
+
+func TestHostClass(t *testing.T) {
+	in := NewInterpreter()
+	var stdout bytes.Buffer
+	in.Stdout = &stdout
+	err := in.DefineClass("Point", func(x, y float64) *point {
+		return &point{X: x, Y: y}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = in.Run([]byte(`
+		var p = Point(1, 2);
+		print p;
+		print Point;
+		p.X = 10;
+		p.label = "origin";
+		print p.label;
+		var q = p.Add(Point(1, 1));
+		print q;
+		var scale = q.Scale;
+		fun double(n) { return n * 2; }
+		scale(double);
+		print q.X + q.Y;
+		print p == p;
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "(1, 2)\nPoint\norigin\n(11, 3)\n28\ntrue\n"
+	if stdout.String() != expected {
+		t.Errorf("printed %q, expected %q", stdout.String(), expected)
+	}
+
+	p, _ := in.Global("p")
+	if host, ok := p.(HostInstance); !ok || host.Value().(*point).X != 10 {
+		t.Errorf("p is %#v", p)
+	}
+
+	errorTests := []struct {
+		source   string
+		expected string
+	}{
+		{`Point(-1, 0).Check();`, "Negative coordinate."},
+		{`Point(0, 0).Z;`, "Undefined property 'Z'."},
+		{`Point(0, 0).X = "a";`, "field 'X': can't convert string to Go value of type float64"},
+		{`Point(0, 0).Add(1);`, "Expected Point instance as argument 1 to 'Add' but got number."},
+		{`Point("a", 0);`, "Expected number as argument 1 to 'Point' but got string."},
+	}
+	for _, test := range errorTests {
+		var runtimeError *RuntimeError
+		err := in.Run([]byte(test.source))
+		if !errors.As(err, &runtimeError) || !strings.HasPrefix(runtimeError.Message, test.expected) {
+			t.Errorf("%s: got %v, expected %q", test.source, err, test.expected)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package lox
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+)
+
+// Interpreter runs Lox programs. Each interpreter owns its globals, its
+// resolver and the current scope, so several of them can run side by side in
+// the same process.
+type Interpreter struct {
+	// Stdout receives the output of print statements.
+	Stdout io.Writer
+	// UseVM makes Run compile programs to bytecode and run them on the VM
+	// instead of walking the syntax tree.
+	UseVM bool
+	// Optimize makes Run rewrite programs with Optimize before running them.
+	Optimize bool
+	// MaxDepth limits how many calls can be in progress at once. A call
+	// beyond it fails with a "Stack overflow." runtime error.
+	MaxDepth int
+
+	globals *Globals
+	// frame holds the local variables of the function being called, or of
+	// the top-level code, which needs scriptSlots of them. Frames take their
+	// slots from stack, which grows as needed.
+	frame       Frame
+	scriptSlots int
+	stack       []Value
+	stackTop    int
+	// tasks, values and calls are the stacks of the evaluator: see eval.go.
+	tasks  []task
+	values []Value
+	calls  []activation
+	// returnValue holds the value of the return statement being run.
+	returnValue Value
+	tailCall    tailCall
+	resolver    *Resolver
+	frames      []CallFrame
+	vm          *VM
+
+	hostClasses map[reflect.Type]*HostClass
+}
+
+// DefaultMaxDepth is the MaxDepth of new interpreters.
+const DefaultMaxDepth = 100000
+
+func NewInterpreter() *Interpreter {
+	in := &Interpreter{
+		Stdout:   os.Stdout,
+		MaxDepth: DefaultMaxDepth,
+		globals:  NewGlobals(),
+
+		hostClasses: make(map[reflect.Type]*HostClass),
+	}
+	in.resolver = NewResolver(in)
+	defineBuiltins(in)
+	return in
+}
+
+// Run tokenizes, parses, resolves and executes a program. The returned error
+// is either a *RuntimeError or every *SyntaxError or *ResolveError found in
+// the program, joined together.
+func (in *Interpreter) Run(source []byte) error {
+	tokens, tokenizeErr := Tokenize(source)
+	statements, parseErr := NewParser(tokens).Parse()
+	if hasUnterminatedString(tokenizeErr) {
+		parseErr = withoutErrorsAtEnd(parseErr)
+	}
+	if err := errors.Join(tokenizeErr, parseErr); err != nil {
+		return errors.Join(err, resolveErrors(statements))
+	}
+	if err := in.Resolve(statements); err != nil {
+		return err
+	}
+	if in.Optimize {
+		statements = Optimize(statements)
+	}
+	if in.UseVM {
+		script, err := in.Compile(statements)
+		if err != nil {
+			return err
+		}
+		return in.ExecuteCompiled(script)
+	}
+	return in.Execute(statements)
+}
+
+// Resolve binds the local variables used by the statements. It must be called
+// before the statements are executed, and they must not be executed if it
+// reports any error.
+func (in *Interpreter) Resolve(statements []Stmt) error {
+	in.scriptSlots = max(in.scriptSlots, in.resolver.resolve(statements))
+	err := errors.Join(in.resolver.errors...)
+	in.resolver.errors = nil
+	return err
+}
+
+// withoutErrorsAtEnd drops the syntax errors reported at the end of the
+// source. After an unterminated string, the parser finds the source ending
+// in the middle of a statement, which isn't a mistake of its own.
+func withoutErrorsAtEnd(err error) error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return err
+	}
+	var kept []error
+	for _, err := range joined.Unwrap() {
+		if syntaxError, ok := err.(*SyntaxError); !ok || syntaxError.Token.Type != EOF {
+			kept = append(kept, err)
+		}
+	}
+	return errors.Join(kept...)
+}
+
+// resolveErrors resolves the statements of a program that won't run because
+// of syntax errors, only to report the errors in them along with those. A
+// throwaway interpreter binds them, so the globals of the one that was to run
+// them are left alone.
+func resolveErrors(statements []Stmt) error {
+	scratch := &Interpreter{globals: NewGlobals()}
+	scratch.resolver = NewResolver(scratch)
+	return scratch.Resolve(statements)
+}
+
+// Execute runs resolved statements in the global scope.
+func (in *Interpreter) Execute(statements []Stmt) (err error) {
+	defer in.recoverRuntimeError(&err, in.save())
+	prev := in.frame
+	in.frame = in.newFrame(in.scriptSlots, nil)
+	depth := len(in.tasks)
+	in.execute(&Block{statements})
+	in.run(depth)
+	in.leaveFrame(prev)
+	return nil
+}
+
+// Evaluate resolves and evaluates a single expression in the global scope.
+func (in *Interpreter) Evaluate(expr Expr) (result any, err error) {
+	if err := in.Resolve([]Stmt{&ExpressionStatement{expr}}); err != nil {
+		return nil, err
+	}
+	defer in.recoverRuntimeError(&err, in.save())
+	depth := len(in.tasks)
+	in.evaluate(expr)
+	in.run(depth)
+	return in.pop().Any(), nil
+}
+
+// savedState is where the interpreter stood when it was entered from Go.
+type savedState struct {
+	frame                                  Frame
+	stackTop, frames, tasks, values, calls int
+}
+
+func (in *Interpreter) save() savedState {
+	return savedState{in.frame, in.stackTop, len(in.frames), len(in.tasks), len(in.values), len(in.calls)}
+}
+
+// recoverRuntimeError turns a runtime error raised by runtimeError back into
+// an error value carrying the call stack at the point of failure. The
+// interpreter is then restored to the state saved when it was entered, so it
+// is ready to run more code.
+func (in *Interpreter) recoverRuntimeError(err *error, saved savedState) {
+	if r := recover(); r != nil {
+		runtimeError, ok := r.(*RuntimeError)
+		if !ok {
+			panic(r)
+		}
+		if runtimeError.Trace == nil {
+			runtimeError.Trace = in.CallStack()
+		}
+		clear(in.stack[saved.stackTop:in.stackTop])
+		in.frame, in.stackTop = saved.frame, saved.stackTop
+		in.frames = in.frames[:saved.frames]
+		in.tasks = in.tasks[:saved.tasks]
+		in.values = in.values[:saved.values]
+		in.calls = in.calls[:saved.calls]
+		in.tailCall.function = nil
+		*err = runtimeError
+	}
+}
+
+// Global returns the value of a global variable.
+func (in *Interpreter) Global(name string) (any, bool) {
+	return in.globals.Lookup(name)
+}
+
+// GlobalNames returns the names of the global variables in no particular
+// order.
+func (in *Interpreter) GlobalNames() []string {
+	return in.globals.Names()
+}
+
+// pushSlots takes slots from the top of the stack, for the variables of a
+// frame or the arguments of a call. When the stack is full a larger one
+// replaces it; the slots already taken stay in the old one.
+func (in *Interpreter) pushSlots(size int) []Value {
+	top := in.stackTop + size
+	if top > len(in.stack) {
+		in.stack = make([]Value, 2*top)
+	}
+	slots := in.stack[in.stackTop:top:top]
+	in.stackTop = top
+	return slots
+}
+
+// popSlots gives the slots last taken back to the stack.
+func (in *Interpreter) popSlots(slots []Value) {
+	clear(slots)
+	in.stackTop -= len(slots)
+}
+
+func (in *Interpreter) newFrame(size int, upvalues []*Cell) Frame {
+	return Frame{in.pushSlots(size), upvalues}
+}
+
+// leaveFrame gives the slots of the current frame back to the stack and
+// makes prev the current frame again.
+func (in *Interpreter) leaveFrame(prev Frame) {
+	in.popSlots(in.frame.slots)
+	in.frame = prev
+}
+
+func (in *Interpreter) lookUpVariable(binding Binding, token *Token) Value {
+	switch binding.Kind {
+	case BK_LOCAL:
+		return in.frame.slots[binding.Index]
+	case BK_CELL:
+		return in.frame.slots[binding.Index].ref.(*Cell).Value
+	case BK_UPVALUE:
+		return in.frame.upvalues[binding.Index].Value
+	}
+	return in.globals.get(binding.Index, token)
+}
+
+func (in *Interpreter) assignVariable(binding Binding, token *Token, value Value) {
+	switch binding.Kind {
+	case BK_LOCAL:
+		in.frame.slots[binding.Index] = value
+	case BK_CELL:
+		in.frame.slots[binding.Index].ref.(*Cell).Value = value
+	case BK_UPVALUE:
+		in.frame.upvalues[binding.Index].Value = value
+	default:
+		in.globals.assign(binding.Index, token, value)
+	}
+}
+
+// define sets a variable being declared. A captured variable gets a new
+// cell, so closures created before the declaration runs again keep theirs.
+func (in *Interpreter) define(binding Binding, value Value) {
+	switch binding.Kind {
+	case BK_LOCAL:
+		in.frame.slots[binding.Index] = value
+	case BK_CELL:
+		in.frame.slots[binding.Index] = objectValue(&Cell{value})
+	default:
+		in.globals.values[binding.Index] = value
+	}
+}
+
+// capture collects the cells of the variables a function declared in the
+// current frame closes over.
+func (in *Interpreter) capture(f *FunctionDeclaration) []*Cell {
+	if len(f.upvalues) == 0 {
+		return nil
+	}
+	cells := make([]*Cell, len(f.upvalues))
+	for i, upvalue := range f.upvalues {
+		if upvalue.isLocal {
+			cells[i] = in.frame.slots[upvalue.index].ref.(*Cell)
+		} else {
+			cells[i] = in.frame.upvalues[upvalue.index]
+		}
+	}
+	return cells
+}
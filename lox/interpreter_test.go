@@ -0,0 +1,358 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSeparateInterpreters(t *testing.T) {
+	first, second := NewInterpreter(), NewInterpreter()
+	var firstOut, secondOut strings.Builder
+	first.Stdout = &firstOut
+	second.Stdout = &secondOut
+
+	first.Run([]byte("var a = 1; fun f() { return a; }"))
+	second.Run([]byte("var a = 2; fun f() { return a * 10; }"))
+	first.Run([]byte("a = a + 1; print f();"))
+	second.Run([]byte("print f();"))
+
+	if got := firstOut.String(); got != "2\n" {
+		t.Errorf("first interpreter printed %q", got)
+	}
+	if got := secondOut.String(); got != "20\n" {
+		t.Errorf("second interpreter printed %q", got)
+	}
+}
+
+func TestErrorsAreReturned(t *testing.T) {
+	in := NewInterpreter()
+	var out strings.Builder
+	in.Stdout = &out
+
+	var syntaxError *SyntaxError
+	if err := in.Run([]byte("print 1 +;")); !errors.As(err, &syntaxError) {
+		t.Errorf("expected a syntax error, got %v", err)
+	}
+	var resolveError *ResolveError
+	if err := in.Run([]byte("return 1;")); !errors.As(err, &resolveError) {
+		t.Errorf("expected a resolve error, got %v", err)
+	}
+	var runtimeError *RuntimeError
+	err := in.Run([]byte("var a = 1;\n{ var b = 2; print a - \"b\"; }"))
+	if !errors.As(err, &runtimeError) {
+		t.Fatalf("expected a runtime error, got %v", err)
+	}
+	if runtimeError.Token.Line != 2 || runtimeError.Message != "Operands must be numbers." {
+		t.Errorf("unexpected runtime error %q", err)
+	}
+
+	if err := in.Run([]byte("print a;")); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "1\n" {
+		t.Errorf("printed %q", got)
+	}
+}
+
+func TestAllResolveErrorsAreReported(t *testing.T) {
+	in := NewInterpreter()
+	err := in.Run([]byte("fun f(a, a) {}\nclass A < A {}\nprint this;\nreturn;"))
+	want := "[line 1] Error at 'a': Already a variable with this name in this scope.\n" +
+		"[line 2] Error at 'A': A class can't inherit from itself.\n" +
+		"[line 3] Error at 'this': Can't use 'this' outside of a class.\n" +
+		"[line 4] Error at 'return': Can't return from top-level code."
+	if err == nil || err.Error() != want {
+		t.Errorf("got errors %q, want %q", err, want)
+	}
+
+	// Syntax errors don't hide the resolve errors in the rest of the program,
+	// and the globals it declares are left undefined.
+	err = in.Run([]byte("var x = ;\nvar b = 1;\nprint this;"))
+	want = "[line 1] Error at ';': Expected expression.\n" +
+		"[line 3] Error at 'this': Can't use 'this' outside of a class."
+	if err == nil || err.Error() != want {
+		t.Errorf("got errors %q, want %q", err, want)
+	}
+	if _, ok := in.Global("b"); ok {
+		t.Error("b was defined by a program that didn't run")
+	}
+}
+
+func TestStringsAreNotKeptAfterRun(t *testing.T) {
+	in := NewInterpreter()
+	in.Stdout = io.Discard
+	for i := range 3 {
+		source := fmt.Sprintf(`class A { m() { return "m"; } } var a = A(); a.f%d = "%d"; print a.m();`, i, i)
+		if err := in.Run([]byte(source)); err != nil {
+			t.Fatal(err)
+		}
+		if len(in.resolver.strings) != 0 {
+			t.Fatalf("the interpreter holds on to %v", in.resolver.strings)
+		}
+	}
+}
+
+func TestRuntimeErrorTrace(t *testing.T) {
+	source := "class A {\n  m(x) { f(x); }\n}\nfun f(x) {\n  return -x;\n}\nA().m(\"a\");"
+	err := NewInterpreter().Run([]byte(source))
+	var runtimeError *RuntimeError
+	if !errors.As(err, &runtimeError) {
+		t.Fatalf("expected a runtime error, got %v", err)
+	}
+	want := []CallFrame{{Function: "m", Class: "A", Line: 7}, {Function: "f", Line: 2}}
+	if len(runtimeError.Trace) != len(want) {
+		t.Fatalf("got trace %v, want %v", runtimeError.Trace, want)
+	}
+	for i := range want {
+		if runtimeError.Trace[i] != want[i] {
+			t.Errorf("frame %d is %+v, want %+v", i, runtimeError.Trace[i], want[i])
+		}
+	}
+	wantMessage := "Operand must be a number.\n[line 5] in f()\n[line 2] in A.m()\n[line 7] in script"
+	if err.Error() != wantMessage {
+		t.Errorf("got %q, want %q", err.Error(), wantMessage)
+	}
+}
+
+func TestGlobalsDefinedLater(t *testing.T) {
+	in := NewInterpreter()
+	var out strings.Builder
+	in.Stdout = &out
+
+	if err := in.Run([]byte("fun f() { return later; }")); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Run([]byte("f();")); err == nil || !strings.HasPrefix(err.Error(), "Undefined variable 'later'.") {
+		t.Errorf("expected later to be undefined, got %v", err)
+	}
+	if _, found := in.Global("later"); found {
+		t.Error("later is reported as a global before it's defined")
+	}
+	if err := in.Run([]byte("var later = 1; { var a = 2; later = later + a; } print f();")); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "3\n" {
+		t.Errorf("printed %q", got)
+	}
+}
+
+func TestCapturedVariables(t *testing.T) {
+	in := NewInterpreter()
+	var out strings.Builder
+	in.Stdout = &out
+
+	source := `
+		fun counters() {
+			var count = 0;
+			fun increment() { count = count + 1; }
+			fun get() { return count; }
+			increment();
+			increment();
+			print get();
+			var last;
+			for (var i = 0; i < 3; i = i + 1) {
+				var j = i;
+				fun f() { return j; }
+				if (i == 1) last = f;
+			}
+			print last();
+		}
+		counters();
+	`
+	if err := in.Run([]byte(source)); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "2\n1\n" {
+		t.Errorf("printed %q", got)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	in := NewInterpreter()
+	var out strings.Builder
+	in.Stdout = &out
+
+	source := `
+		fun count(n) { if (n == 0) return "done"; return count(n - 1); }
+		print count(1000000);
+		fun even(n) { if (n == 0) return true; return odd(n - 1); }
+		fun odd(n) { if (n == 0) return false; return even(n - 1); }
+		print even(100001);
+		fun fail(n) { if (n == 0) return -"a"; return fail(n - 1); }
+		fun start() { var result = fail(2); return result; }
+		start();
+	`
+	err := in.Run([]byte(source))
+	if got := out.String(); got != "done\nfalse\n" {
+		t.Errorf("printed %q", got)
+	}
+	var runtimeError *RuntimeError
+	if !errors.As(err, &runtimeError) {
+		t.Fatalf("expected a runtime error, got %v", err)
+	}
+	want := "Operand must be a number.\n[line 7] in fail()\n[2 tail calls elided]\n[line 8] in start()\n[line 9] in script"
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	source := "fun f(n) {\n  return 1 + f(n + 1);\n}\nf(0);"
+	for _, useVM := range []bool{false, true} {
+		in := NewInterpreter()
+		in.UseVM = useVM
+		in.MaxDepth = 50
+		err := in.Run([]byte(source))
+		var runtimeError *RuntimeError
+		if !errors.As(err, &runtimeError) || runtimeError.Message != "Stack overflow." {
+			t.Fatalf("expected a stack overflow, got %v", err)
+		}
+		if len(runtimeError.Trace) != 50 || runtimeError.Token.Line != 2 {
+			t.Errorf("overflowed at line %d with %d calls", runtimeError.Token.Line, len(runtimeError.Trace))
+		}
+		lines := strings.Split(err.Error(), "\n")
+		if len(lines) != 2*traceEnds+3 || lines[traceEnds+1] != "[30 more calls]" {
+			t.Errorf("got trace %q", lines)
+		}
+
+		// The interpreter is left ready to run more code, including calls as
+		// deep as the limit allows.
+		var out strings.Builder
+		in.Stdout = &out
+		if err := in.Run([]byte("fun g(n) { if (n == 0) return 0; return 1 + g(n - 1); } print g(49);")); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != "49\n" {
+			t.Errorf("printed %q", got)
+		}
+
+		// Calls in tail position take no room of their own, even at the limit.
+		out.Reset()
+		in.MaxDepth = 4
+		source := `fun g() { return "g"; } fun h() { return g(); }
+			fun down(n) { if (n == 0) return h(); return down(n - 1) + ""; }
+			print down(3);`
+		if err := in.Run([]byte(source)); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != "g\n" {
+			t.Errorf("printed %q", got)
+		}
+	}
+}
+
+func TestBreakAndContinue(t *testing.T) {
+	source := `
+		for (var i = 0; i < 6; i = i + 1) {
+			if (i == 1) continue;
+			if (i == 4) break;
+			print i;
+		}
+		outer: for (var i = 0; i < 3; i = i + 1) {
+			for (var j = 0; j < 3; j = j + 1) {
+				fun f() { return i * 10 + j; }
+				if (j == 1) continue outer;
+				if (i == 2) break outer;
+				print f();
+			}
+		}
+		var n = 0;
+		while (true) { n = n + 1; { var m = n; if (m < 3) continue; } break; }
+		print n;
+	`
+	walked, compiled := runBoth(t, source, nil)
+	if want := "0\n2\n3\n0\n10\n3\n"; walked != want {
+		t.Errorf("tree-walker printed %q, want %q", walked, want)
+	}
+	if compiled != walked {
+		t.Errorf("VM printed %q, tree-walker printed %q", compiled, walked)
+	}
+
+	err := NewInterpreter().Run([]byte("break;\nwhile (true) { fun f() { continue; } }\nwhile (true) { break nope; }"))
+	want := "[line 1] Error at 'break': Can't use 'break' outside of a loop.\n" +
+		"[line 2] Error at 'continue': Can't use 'continue' outside of a loop.\n" +
+		"[line 3] Error at 'nope': No loop labeled 'nope' around this one."
+	if err == nil || err.Error() != want {
+		t.Errorf("got errors %q, want %q", err, want)
+	}
+}
+
+func TestLists(t *testing.T) {
+	source := `
+		var xs = [1, 2.5, "three", [4]];
+		print xs;
+		xs[0] = xs[0] + 10;
+		xs.push(nil);
+		xs.insert(1, "one");
+		print xs.remove(2);
+		print xs.pop();
+		print xs;
+		print xs.len();
+		print xs.contains("three");
+		print xs.contains([4]);
+		var self = [];
+		self.push(self);
+		print self;
+		print xs[4];
+	`
+	walked, compiled := runBoth(t, source, nil)
+	want := "[1, 2.5, three, [4]]\n2.5\nnil\n[11, one, three, [4]]\n4\ntrue\nfalse\n[[...]]\n" +
+		"test.lox:16:9: runtime error: Index 4 out of bounds for list of length 4.\n\t\tprint xs[4];\n\t\t      ^~~~~\n"
+	if walked != want {
+		t.Errorf("tree-walker printed %q, want %q", walked, want)
+	}
+	if compiled != walked {
+		t.Errorf("VM printed %q, tree-walker printed %q", compiled, walked)
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	source := `
+		var count = 3;
+		var items = ["a", nil];
+		print "total: ${count * 2.5} for ${items}";
+		print "nested ${"inner ${count + 1}"} and \${escaped} ${"}"}";
+		fun label(x) { return "<${x}>"; }
+		print "${label(label(count))}${label}";
+		print "bad ${count + nil}";
+	`
+	walked, compiled := runBoth(t, source, nil)
+	want := "total: 7.5 for [a, nil]\nnested inner 4 and ${escaped} }\n<<3>><fn label>\n" +
+		"test.lox:8:16: runtime error: Operands must be two numbers or two strings.\n\t\tprint \"bad ${count + nil}\";\n\t\t             ^~~~~~~~~~~\n"
+	if walked != want {
+		t.Errorf("tree-walker printed %q, want %q", walked, want)
+	}
+	if compiled != walked {
+		t.Errorf("VM printed %q, tree-walker printed %q", compiled, walked)
+	}
+}
+
+func TestMaps(t *testing.T) {
+	source := `
+		var m = {"a": 1, 2: "two", nil: true,};
+		m["c"] = m["a"] + 2;
+		m["a"] = 0;
+		print m;
+		print m.delete(2);
+		m[2] = "again";
+		print m.keys();
+		print m.values();
+		print m.has("c");
+		print m.len();
+		{"statement": 1};
+		{ print "block"; }
+		print m["missing"];
+	`
+	walked, compiled := runBoth(t, source, nil)
+	want := "{a: 0, 2: two, nil: true, c: 3}\ntrue\n[a, nil, c, 2]\n[0, true, 3, again]\ntrue\n4\nblock\n" +
+		"test.lox:14:9: runtime error: Undefined key 'missing'.\n\t\tprint m[\"missing\"];\n\t\t      ^~~~~~~~~~~~\n"
+	if walked != want {
+		t.Errorf("tree-walker printed %q, want %q", walked, want)
+	}
+	if compiled != walked {
+		t.Errorf("VM printed %q, tree-walker printed %q", compiled, walked)
+	}
+}
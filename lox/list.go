@@ -0,0 +1,128 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// LoxList is an ordered list of Lox values.
+type LoxList struct {
+	elements []Value
+}
+
+func NewList(elements []any) *LoxList {
+	list := &LoxList{make([]Value, len(elements))}
+	for i, element := range elements {
+		list.elements[i] = ValueOf(element)
+	}
+	return list
+}
+
+// Elements returns the values in the list.
+func (l *LoxList) Elements() []any {
+	elements := make([]any, len(l.elements))
+	for i, element := range l.elements {
+		elements[i] = element.Any()
+	}
+	return elements
+}
+
+func (l *LoxList) String() string {
+	sb := strings.Builder{}
+	l.format(&sb, nil)
+	return sb.String()
+}
+
+// format writes the list the way print shows it. A list that contains
+// itself, directly or not, is shown as [...] inside.
+func (l *LoxList) format(sb *strings.Builder, enclosing []any) {
+	if slices.Contains(enclosing, any(l)) {
+		sb.WriteString("[...]")
+		return
+	}
+	enclosing = append(enclosing, l)
+	sb.WriteByte('[')
+	for i, element := range l.elements {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		formatValue(sb, element, enclosing)
+	}
+	sb.WriteByte(']')
+}
+
+// index converts a Lox value to an index into the list. With end set, the
+// length of the list is allowed too, for inserting at the end.
+func (l *LoxList) index(index Value, end bool) (int, error) {
+	if index.kind != VK_NUMBER || index.number != math.Trunc(index.number) {
+		return 0, errors.New("List index must be a whole number.")
+	}
+	length := len(l.elements)
+	if end {
+		length++
+	}
+	if index.number < 0 || index.number >= float64(length) {
+		return 0, fmt.Errorf("Index %s out of bounds for list of length %d.", index, len(l.elements))
+	}
+	return int(index.number), nil
+}
+
+// method returns a built-in method of lists bound to the list, or nil if
+// there is none with the name.
+func (l *LoxList) method(name string) *NativeFunction {
+	method := &NativeFunction{Name: name}
+	switch name {
+	case "push":
+		method.Params = []ParamType{PT_ANY}
+		method.Fn = func(in *Interpreter, arguments []any) (any, error) {
+			l.elements = append(l.elements, ValueOf(arguments[0]))
+			return nil, nil
+		}
+	case "pop":
+		method.Fn = func(in *Interpreter, arguments []any) (any, error) {
+			if len(l.elements) == 0 {
+				return nil, errors.New("Can't pop from an empty list.")
+			}
+			last := l.elements[len(l.elements)-1]
+			l.elements = l.elements[:len(l.elements)-1]
+			return last.Any(), nil
+		}
+	case "insert":
+		method.Params = []ParamType{PT_NUMBER, PT_ANY}
+		method.Fn = func(in *Interpreter, arguments []any) (any, error) {
+			i, err := l.index(ValueOf(arguments[0]), true)
+			if err != nil {
+				return nil, err
+			}
+			l.elements = slices.Insert(l.elements, i, ValueOf(arguments[1]))
+			return nil, nil
+		}
+	case "remove":
+		method.Params = []ParamType{PT_NUMBER}
+		method.Fn = func(in *Interpreter, arguments []any) (any, error) {
+			i, err := l.index(ValueOf(arguments[0]), false)
+			if err != nil {
+				return nil, err
+			}
+			removed := l.elements[i]
+			l.elements = slices.Delete(l.elements, i, i+1)
+			return removed.Any(), nil
+		}
+	case "len":
+		method.Fn = func(in *Interpreter, arguments []any) (any, error) {
+			return float64(len(l.elements)), nil
+		}
+	case "contains":
+		method.Params = []ParamType{PT_ANY}
+		method.Fn = func(in *Interpreter, arguments []any) (any, error) {
+			value := ValueOf(arguments[0])
+			return slices.ContainsFunc(l.elements, value.equals), nil
+		}
+	default:
+		return nil
+	}
+	return method
+}
@@ -0,0 +1,202 @@
+package lox
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+)
+
+// LoxMap maps Lox values to Lox values, keeping its entries in the order
+// their keys were added. Keys are strings, numbers, booleans, nil or
+// instances, which are compared by identity.
+type LoxMap struct {
+	// index gives the position in entries of each key, held as mapKey
+	// returns it.
+	index   map[Value]int
+	entries []mapEntry
+	// deleted counts the entries that were deleted but still take up a
+	// position, until there are enough of them to compact entries.
+	deleted int
+}
+
+type mapEntry struct {
+	key, value Value
+	deleted    bool
+}
+
+func NewMap() *LoxMap {
+	return &LoxMap{index: make(map[Value]int)}
+}
+
+// newMapFrom makes a map from its keys and values, alternating.
+func newMapFrom(entries []Value) (*LoxMap, error) {
+	m := &LoxMap{index: make(map[Value]int, len(entries)/2)}
+	for i := 0; i < len(entries); i += 2 {
+		key, err := mapKey(entries[i])
+		if err != nil {
+			return nil, err
+		}
+		m.set(key, entries[i+1])
+	}
+	return m, nil
+}
+
+// mapKey checks a value can be used as a key and returns it in the form the
+// index holds it: strings are flattened, so that equal strings are the same
+// key.
+func mapKey(key Value) (Value, error) {
+	switch key.kind {
+	case VK_NIL, VK_BOOL:
+		return key, nil
+	case VK_NUMBER:
+		if math.IsNaN(key.number) {
+			return nilValue, errors.New("NaN can't be a map key.")
+		}
+		return key, nil
+	case VK_STRING:
+		return stringValue(key.str()), nil
+	}
+	if _, ok := key.ref.(*LoxInstance); ok {
+		return key, nil
+	}
+	return nilValue, errors.New("Map keys must be strings, numbers, booleans, nil or instances.")
+}
+
+// Len returns the number of entries in the map.
+func (m *LoxMap) Len() int {
+	return len(m.index)
+}
+
+func (m *LoxMap) get(key Value) (Value, bool) {
+	if i, found := m.index[key]; found {
+		return m.entries[i].value, true
+	}
+	return nilValue, false
+}
+
+// set adds or replaces an entry. A key that is replaced keeps its place in
+// the order.
+func (m *LoxMap) set(key, value Value) {
+	if i, found := m.index[key]; found {
+		m.entries[i].value = value
+		return
+	}
+	m.index[key] = len(m.entries)
+	m.entries = append(m.entries, mapEntry{key: key, value: value})
+}
+
+// delete removes an entry and reports whether there was one.
+func (m *LoxMap) delete(key Value) bool {
+	i, found := m.index[key]
+	if !found {
+		return false
+	}
+	delete(m.index, key)
+	m.entries[i] = mapEntry{deleted: true}
+	m.deleted++
+	if m.deleted > len(m.entries)/2 {
+		m.compact()
+	}
+	return true
+}
+
+// compact drops the deleted entries.
+func (m *LoxMap) compact() {
+	live := m.entries[:0]
+	for _, entry := range m.entries {
+		if !entry.deleted {
+			m.index[entry.key] = len(live)
+			live = append(live, entry)
+		}
+	}
+	clear(m.entries[len(live):])
+	m.entries, m.deleted = live, 0
+}
+
+func (m *LoxMap) String() string {
+	sb := strings.Builder{}
+	m.format(&sb, nil)
+	return sb.String()
+}
+
+func (m *LoxMap) format(sb *strings.Builder, enclosing []any) {
+	if slices.Contains(enclosing, any(m)) {
+		sb.WriteString("{...}")
+		return
+	}
+	enclosing = append(enclosing, m)
+	sb.WriteByte('{')
+	first := true
+	for _, entry := range m.entries {
+		if entry.deleted {
+			continue
+		}
+		if !first {
+			sb.WriteString(", ")
+		}
+		first = false
+		formatValue(sb, entry.key, enclosing)
+		sb.WriteString(": ")
+		formatValue(sb, entry.value, enclosing)
+	}
+	sb.WriteByte('}')
+}
+
+// list returns a list of the keys or of the values of the map, in order.
+func (m *LoxMap) list(values bool) *LoxList {
+	list := &LoxList{make([]Value, 0, m.Len())}
+	for _, entry := range m.entries {
+		if entry.deleted {
+			continue
+		}
+		if values {
+			list.elements = append(list.elements, entry.value)
+		} else {
+			list.elements = append(list.elements, entry.key)
+		}
+	}
+	return list
+}
+
+// method returns a built-in method of maps bound to the map, or nil if there
+// is none with the name.
+func (m *LoxMap) method(name string) *NativeFunction {
+	method := &NativeFunction{Name: name}
+	switch name {
+	case "keys":
+		method.Fn = func(in *Interpreter, arguments []any) (any, error) {
+			return m.list(false), nil
+		}
+	case "values":
+		method.Fn = func(in *Interpreter, arguments []any) (any, error) {
+			return m.list(true), nil
+		}
+	case "has":
+		method.Params = []ParamType{PT_ANY}
+		method.Fn = func(in *Interpreter, arguments []any) (any, error) {
+			key, err := mapKey(ValueOf(arguments[0]))
+			if err != nil {
+				return nil, err
+			}
+			_, found := m.index[key]
+			return found, nil
+		}
+	case "delete":
+		method.Params = []ParamType{PT_ANY}
+		method.Fn = func(in *Interpreter, arguments []any) (any, error) {
+			key, err := mapKey(ValueOf(arguments[0]))
+			if err != nil {
+				return nil, err
+			}
+			return m.delete(key), nil
+		}
+	case "len":
+		method.Fn = func(in *Interpreter, arguments []any) (any, error) {
+			return float64(m.Len()), nil
+		}
+	default:
+		return nil
+	}
+	return method
+}
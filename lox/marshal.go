@@ -0,0 +1,408 @@
+package lox
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ToLox converts a Go value to a Lox value. Booleans and strings convert
+// directly, every integer and floating-point type becomes a number, slices
+// and arrays become lists, maps become Lox maps with their entries sorted by
+// key, and structs become instances whose fields hold the converted fields.
+// Struct fields can be renamed with a `lox:"name"` tag or skipped with
+// `lox:"-"`. Pointers are followed, and Lox values are passed through
+// unchanged. A value that contains itself through pointers, slices or maps
+// can't be converted.
+func ToLox(value any) (any, error) {
+	switch value.(type) {
+	case nil, bool, float64, string:
+		return value, nil
+	}
+	c := &toLoxConverter{
+		classes:  make(map[reflect.Type]*LoxClass),
+		visiting: make(map[reference]bool),
+	}
+	return c.convert(reflect.ValueOf(value), "value")
+}
+
+type toLoxConverter struct {
+	classes map[reflect.Type]*LoxClass
+	// visiting holds the pointers, slices and maps being converted, to
+	// catch values that contain themselves.
+	visiting map[reference]bool
+}
+
+// reference identifies what a pointer, slice or map refers to. Slices of
+// different lengths over the same array are told apart.
+type reference struct {
+	pointer uintptr
+	typ     reflect.Type
+	length  int
+}
+
+// maxExactInteger is the largest integer a number holds without rounding.
+const maxExactInteger = 1 << 53
+
+func (c *toLoxConverter) convert(v reflect.Value, path string) (any, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.CanInterface() {
+		switch value := v.Interface().(type) {
+		case LoxCallable, *LoxInstance, *LoxList, *LoxMap, HostObject:
+			return value, nil
+		}
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			break
+		}
+		ref := reference{pointer: v.Pointer(), typ: v.Type()}
+		if v.Kind() == reflect.Slice {
+			ref.length = v.Len()
+		}
+		if c.visiting[ref] {
+			return nil, fmt.Errorf("lox: %s: can't convert Go value of type %s that contains itself", path, v.Type())
+		}
+		c.visiting[ref] = true
+		defer delete(c.visiting, ref)
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := v.Int(); n > maxExactInteger || n < -maxExactInteger {
+			return nil, fmt.Errorf("lox: %s: integer %d can't be represented exactly as a number", path, n)
+		}
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n := v.Uint(); n > maxExactInteger {
+			return nil, fmt.Errorf("lox: %s: integer %d can't be represented exactly as a number", path, n)
+		}
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return c.convert(v.Elem(), path)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		elements := make([]any, v.Len())
+		for i := range elements {
+			element, err := c.convert(v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return NewList(elements), nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		entries := make([]mapEntry, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := c.convert(iter.Key(), path+" key")
+			if err != nil {
+				return nil, err
+			}
+			entry := mapEntry{key: ValueOf(key)}
+			if entry.key, err = mapKey(entry.key); err != nil {
+				return nil, fmt.Errorf("lox: %s key: %s", path, err)
+			}
+			value, err := c.convert(iter.Value(), fmt.Sprintf("%s[%s]", path, entry.key))
+			if err != nil {
+				return nil, err
+			}
+			entry.value = ValueOf(value)
+			entries = append(entries, entry)
+		}
+		slices.SortStableFunc(entries, func(a, b mapEntry) int {
+			return compareKeys(a.key, b.key)
+		})
+		m := NewMap()
+		for _, entry := range entries {
+			m.set(entry.key, entry.value)
+		}
+		return m, nil
+	case reflect.Struct:
+		instance := &LoxInstance{c.class(v.Type()), make(map[string]Value)}
+		for _, field := range structFields(v.Type()) {
+			value, err := c.convert(v.FieldByIndex(field.index), path+"."+field.name)
+			if err != nil {
+				return nil, err
+			}
+			instance.fields[field.name] = ValueOf(value)
+		}
+		return instance, nil
+	}
+	return nil, fmt.Errorf("lox: %s: can't convert Go value of type %s to a Lox value", path, v.Type())
+}
+
+// compareKeys orders map keys for ToLox: nil, then booleans, numbers and
+// strings, each in their natural order, then instances, whose order is
+// unspecified.
+func compareKeys(a, b Value) int {
+	if a.kind != b.kind {
+		return cmp.Compare(a.kind, b.kind)
+	}
+	switch a.kind {
+	case VK_BOOL, VK_NUMBER:
+		return cmp.Compare(a.number, b.number)
+	case VK_STRING:
+		return strings.Compare(a.str(), b.str())
+	}
+	return 0
+}
+
+// class returns the class given to instances converted from a Go type. Each
+// conversion shares one class per type.
+func (c *toLoxConverter) class(t reflect.Type) *LoxClass {
+	if class, ok := c.classes[t]; ok {
+		return class
+	}
+	name := t.Name()
+	if name == "" {
+		name = "Object"
+	}
+	class := &LoxClass{name, nil, map[string]Method{}}
+	c.classes[t] = class
+	return class
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+// structFields lists the exported fields of a struct type under their Lox
+// names, including the fields of embedded structs.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("lox"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag, _, _ = strings.Cut(tag, ","); tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, structField{name, field.Index})
+	}
+	return fields
+}
+
+// FromLox stores a Lox value in the Go value target points to, converting it
+// to the target type. Numbers convert to integer types when they are whole and
+// in range, and to float32 when they are in its range, rounded to its
+// precision. Lists convert to slices and arrays, maps convert to Go maps, and
+// instances convert to structs and maps with string keys. An any target
+// receives float64, string, bool, nil, []any for lists, map[any]any for maps
+// and map[string]any for instances, while functions, classes and the
+// instances used as map keys are stored as they are.
+func FromLox(value any, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("lox: FromLox needs a non-nil pointer, got %T", target)
+	}
+	return fromLox(value, v.Elem(), "value")
+}
+
+func fromLox(value any, v reflect.Value, path string) error {
+	fail := func() error {
+		return fmt.Errorf("lox: %s: can't convert %s to Go value of type %s", path, typeName(value), v.Type())
+	}
+	if value == nil {
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			v.SetZero()
+			return nil
+		}
+		return fail()
+	}
+	if reflect.TypeOf(value).AssignableTo(v.Type()) && v.Kind() != reflect.Interface {
+		v.Set(reflect.ValueOf(value))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			if !reflect.TypeOf(value).Implements(v.Type()) {
+				return fail()
+			}
+			v.Set(reflect.ValueOf(value))
+			return nil
+		}
+		if goValue := toGo(value); goValue == nil {
+			v.SetZero()
+		} else {
+			v.Set(reflect.ValueOf(goValue))
+		}
+		return nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return fromLox(value, v.Elem(), path)
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return fail()
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return fail()
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := value.(float64)
+		if !ok {
+			return fail()
+		}
+		if n != math.Trunc(n) || n >= 1<<63 || n < -1<<63 || v.OverflowInt(int64(n)) {
+			return fmt.Errorf("lox: %s: number %s doesn't fit in Go value of type %s", path, Stringify(n), v.Type())
+		}
+		v.SetInt(int64(n))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := value.(float64)
+		if !ok {
+			return fail()
+		}
+		if n != math.Trunc(n) || n < 0 || n >= 1<<64 || v.OverflowUint(uint64(n)) {
+			return fmt.Errorf("lox: %s: number %s doesn't fit in Go value of type %s", path, Stringify(n), v.Type())
+		}
+		v.SetUint(uint64(n))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		n, ok := value.(float64)
+		if !ok {
+			return fail()
+		}
+		if v.OverflowFloat(n) {
+			return fmt.Errorf("lox: %s: number %s doesn't fit in Go value of type %s", path, Stringify(n), v.Type())
+		}
+		v.SetFloat(n)
+		return nil
+	case reflect.Slice, reflect.Array:
+		list, ok := value.(*LoxList)
+		if !ok {
+			return fail()
+		}
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), len(list.elements), len(list.elements)))
+		} else if v.Len() != len(list.elements) {
+			return fmt.Errorf("lox: %s: can't convert list of %d elements to Go value of type %s", path, len(list.elements), v.Type())
+		}
+		for i, element := range list.elements {
+			if err := fromLox(element.Any(), v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if m, ok := value.(*LoxMap); ok {
+			return fromLoxMap(m, v, path)
+		}
+		instance, ok := value.(*LoxInstance)
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return fail()
+		}
+		v.Set(reflect.MakeMapWithSize(v.Type(), len(instance.fields)))
+		for name, field := range instance.fields {
+			element := reflect.New(v.Type().Elem()).Elem()
+			if err := fromLox(field.Any(), element, path+"."+name); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(name).Convert(v.Type().Key()), element)
+		}
+		return nil
+	case reflect.Struct:
+		instance, ok := value.(*LoxInstance)
+		if !ok {
+			return fail()
+		}
+		for _, field := range structFields(v.Type()) {
+			fieldValue, found := instance.fields[field.name]
+			if !found {
+				continue
+			}
+			if err := fromLox(fieldValue.Any(), v.FieldByIndex(field.index), path+"."+field.name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fail()
+}
+
+// fromLoxMap stores the entries of a map in a Go map, converting the keys
+// and values to its key and element types.
+func fromLoxMap(m *LoxMap, v reflect.Value, path string) error {
+	v.Set(reflect.MakeMapWithSize(v.Type(), m.Len()))
+	for _, entry := range m.entries {
+		if entry.deleted {
+			continue
+		}
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := fromLox(entry.key.Any(), key, path+" key"); err != nil {
+			return err
+		}
+		element := reflect.New(v.Type().Elem()).Elem()
+		if err := fromLox(entry.value.Any(), element, fmt.Sprintf("%s[%s]", path, entry.key)); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, element)
+	}
+	return nil
+}
+
+// toGo converts a Lox value to the natural Go value for an any target. Map
+// keys are kept as they are, since an instance key converted to a Go map
+// couldn't be a key of a Go map.
+func toGo(value any) any {
+	switch value := value.(type) {
+	case *LoxList:
+		elements := make([]any, len(value.elements))
+		for i, element := range value.elements {
+			elements[i] = toGo(element.Any())
+		}
+		return elements
+	case *LoxMap:
+		entries := make(map[any]any, value.Len())
+		for _, entry := range value.entries {
+			if !entry.deleted {
+				entries[entry.key.Any()] = toGo(entry.value.Any())
+			}
+		}
+		return entries
+	case *LoxInstance:
+		fields := make(map[string]any, len(value.fields))
+		for name, field := range value.fields {
+			fields[name] = toGo(field.Any())
+		}
+		return fields
+	}
+	return value
+}
@@ -0,0 +1,173 @@
+package lox
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testItem struct {
+	Name     string `lox:"name"`
+	Quantity int    `lox:"qty"`
+	Price    float64
+	Secret   string `lox:"-"`
+	internal bool
+}
+
+type testOrder struct {
+	ID    uint16
+	Items []testItem
+	Tags  map[string]string
+	Note  *string
+}
+
+func TestToLoxAndBack(t *testing.T) {
+	note := "fragile"
+	order := testOrder{
+		ID:    7,
+		Items: []testItem{{"apple", 3, 0.5, "x", true}, {"pear", 1, 1.25, "y", false}},
+		Tags:  map[string]string{"priority": "high"},
+		Note:  &note,
+	}
+	value, err := ToLox(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := NewInterpreter()
+	var out strings.Builder
+	in.Stdout = &out
+	in.globals.Define("order", value)
+	err = in.Run([]byte(`
+		print order;
+		print order.ID;
+		print order.Items;
+		print order.Tags["priority"];
+		print order.Note;
+		order.ID = order.ID + 1;
+		order.Note = nil;
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "testOrder instance\n7\n[testItem instance, testItem instance]\nhigh\nfragile\n"
+	if out.String() != want {
+		t.Errorf("printed %q, want %q", out.String(), want)
+	}
+
+	var back testOrder
+	if err := FromLox(value, &back); err != nil {
+		t.Fatal(err)
+	}
+	order.ID = 8
+	order.Note = nil
+	order.Items[0].Secret, order.Items[1].Secret = "", ""
+	order.Items[0].internal = false
+	if !reflect.DeepEqual(back, order) {
+		t.Errorf("got %+v, want %+v", back, order)
+	}
+
+	var generic any
+	if err := FromLox(value, &generic); err != nil {
+		t.Fatal(err)
+	}
+	item := generic.(map[string]any)["Items"].([]any)[1].(map[string]any)
+	if item["name"] != "pear" || item["qty"] != 1.0 {
+		t.Errorf("unexpected generic conversion %v", item)
+	}
+}
+
+func TestMapToLoxAndBack(t *testing.T) {
+	numbers := map[int]string{3: "three", 1: "one", 2: "two"}
+	value, err := ToLox(numbers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := value.(*LoxMap)
+	if want := "{1: one, 2: two, 3: three}"; m.String() != want {
+		t.Errorf("printed %q, want %q", m.String(), want)
+	}
+	var back map[int]string
+	if err := FromLox(m, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, numbers) {
+		t.Errorf("got %v, want %v", back, numbers)
+	}
+
+	m = NewMap()
+	m.set(numberValue(1), stringValue("one"))
+	m.set(numberValue(2), stringValue("two"))
+	var generic any
+	if err := FromLox(m, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[any]any{1.0: "one", 2.0: "two"}; !reflect.DeepEqual(generic, want) {
+		t.Errorf("got %v, want %v", generic, want)
+	}
+
+	key := &LoxInstance{&LoxClass{name: "A"}, map[string]Value{}}
+	m = NewMap()
+	m.set(ValueOf(key), numberValue(1))
+	if err := FromLox(m, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[any]any{key: 1.0}; !reflect.DeepEqual(generic, want) {
+		t.Errorf("got %v, want %v", generic, want)
+	}
+}
+
+func TestConversionErrors(t *testing.T) {
+	if _, err := ToLox(map[float64]string{math.NaN(): "a"}); err == nil || err.Error() != "lox: value key: NaN can't be a map key." {
+		t.Errorf("unexpected error %v", err)
+	}
+	type node struct{ Next *node }
+	n := &node{}
+	n.Next = n
+	if _, err := ToLox(n); err == nil || err.Error() != "lox: value.Next: can't convert Go value of type *lox.node that contains itself" {
+		t.Errorf("unexpected error %v", err)
+	}
+	shared := &testItem{Name: "shared"}
+	if _, err := ToLox([]*testItem{shared, shared}); err != nil {
+		t.Errorf("converting a value used twice gave %v", err)
+	}
+	if _, err := ToLox(struct{ C chan int }{}); err == nil || err.Error() != "lox: value.C: can't convert Go value of type chan int to a Lox value" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	var small int8
+	if err := FromLox(300.0, &small); err == nil || err.Error() != "lox: value: number 300 doesn't fit in Go value of type int8" {
+		t.Errorf("unexpected error %v", err)
+	}
+	var wide int64
+	if err := FromLox(float64(1<<63), &wide); err == nil {
+		t.Errorf("expected an error converting 2^63 to int64, got %d", wide)
+	}
+	if err := FromLox(float64(-1<<63), &wide); err != nil || wide != -1<<63 {
+		t.Errorf("converting -2^63 to int64 gave %d, %v", wide, err)
+	}
+	var unsigned uint64
+	if err := FromLox(float64(1<<64), &unsigned); err == nil {
+		t.Errorf("expected an error converting 2^64 to uint64, got %d", unsigned)
+	}
+	var single float32
+	if err := FromLox(1e300, &single); err == nil || err.Error() != "lox: value: number 1e+300 doesn't fit in Go value of type float32" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := FromLox(0.1, &single); err != nil || single != 0.1 {
+		t.Errorf("converting 0.1 to float32 gave %v, %v", single, err)
+	}
+	var whole int
+	if err := FromLox(1.5, &whole); err == nil {
+		t.Error("expected an error converting 1.5 to int")
+	}
+	var items []testItem
+	list := NewList([]any{&LoxInstance{&LoxClass{name: "Item"}, map[string]Value{"qty": stringValue("many")}}})
+	if err := FromLox(list, &items); err == nil || err.Error() != "lox: value[0].qty: can't convert string to Go value of type int" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := FromLox(1.0, items); err == nil {
+		t.Error("expected an error for a target that is not a pointer")
+	}
+}
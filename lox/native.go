@@ -0,0 +1,231 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ParamType is the type of value a native function accepts for a parameter.
+type ParamType uint8
+
+const (
+	PT_ANY ParamType = iota
+	PT_NUMBER
+	PT_STRING
+	PT_BOOL
+	PT_CALLABLE
+	PT_INSTANCE
+)
+
+func (pt ParamType) String() string {
+	switch pt {
+	case PT_NUMBER:
+		return "number"
+	case PT_STRING:
+		return "string"
+	case PT_BOOL:
+		return "boolean"
+	case PT_CALLABLE:
+		return "function"
+	case PT_INSTANCE:
+		return "instance"
+	}
+	return "any"
+}
+
+func (pt ParamType) accepts(value any) bool {
+	switch pt {
+	case PT_NUMBER:
+		_, ok := value.(float64)
+		return ok
+	case PT_STRING:
+		_, ok := value.(string)
+		return ok
+	case PT_BOOL:
+		_, ok := value.(bool)
+		return ok
+	case PT_CALLABLE:
+		_, ok := value.(LoxCallable)
+		return ok
+	case PT_INSTANCE:
+		_, ok := value.(*LoxInstance)
+		return ok
+	}
+	return true
+}
+
+// typeName describes the type of a Lox value in error messages.
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case *LoxClass, *HostClass:
+		return "class"
+	case LoxCallable:
+		return "function"
+	case *LoxInstance, HostInstance:
+		return "instance"
+	case *LoxList:
+		return "list"
+	case *LoxMap:
+		return "map"
+	case *Namespace:
+		return "namespace"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// NativeFunc implements a native function. The arguments have already been
+// checked against the declared parameters. A returned error is reported as a
+// runtime error at the call site.
+type NativeFunc func(in *Interpreter, arguments []any) (any, error)
+
+// NativeFunction is a Go function that can be called from Lox.
+type NativeFunction struct {
+	Name   string
+	Params []ParamType
+	// Variadic lets the function take any number of extra arguments of the
+	// type of its last parameter, including none. Without parameters, it
+	// takes any number of arguments of any type.
+	Variadic bool
+	Fn       NativeFunc
+}
+
+func (f *NativeFunction) Arity() int {
+	if f.Variadic {
+		return max(len(f.Params)-1, 0)
+	}
+	return len(f.Params)
+}
+
+func (f *NativeFunction) String() string {
+	return "<native fn>"
+}
+
+// nativeCallable is implemented by callables that run Go code and report
+// failures as errors instead of raising runtime errors themselves.
+type nativeCallable interface {
+	LoxCallable
+	call(in *Interpreter, arguments []Value) (Value, error)
+}
+
+func (f *NativeFunction) Call(in *Interpreter, arguments []Value) Value {
+	result, err := f.call(in, arguments)
+	if err != nil {
+		runtimeError(nil, err.Error())
+	}
+	return result
+}
+
+// call checks the arguments and runs the function, converting the values
+// it is given and returns.
+func (f *NativeFunction) call(in *Interpreter, arguments []Value) (Value, error) {
+	if f.Variadic && len(arguments) < f.Arity() {
+		return nilValue, fmt.Errorf("Expected at least %d arguments but got %d.", f.Arity(), len(arguments))
+	}
+	if !f.Variadic && len(arguments) != f.Arity() {
+		return nilValue, fmt.Errorf("Expected %d arguments but got %d.", f.Arity(), len(arguments))
+	}
+	args := make([]any, len(arguments))
+	for i, argument := range arguments {
+		args[i] = argument.Any()
+		param := PT_ANY
+		if len(f.Params) > 0 {
+			param = f.Params[min(i, len(f.Params)-1)]
+		}
+		if !param.accepts(args[i]) {
+			return nilValue, fmt.Errorf("Expected %s as argument %d to '%s' but got %s.", param, i+1, f.Name, typeName(args[i]))
+		}
+	}
+	result, err := f.Fn(in, args)
+	if err != nil {
+		return nilValue, err
+	}
+	converted, err := ToLox(result)
+	if err != nil {
+		return nilValue, fmt.Errorf("Can't convert the result of '%s': %w", f.Name, err)
+	}
+	return ValueOf(converted), nil
+}
+
+// Namespace groups native functions and other values under a name, so they
+// can be reached from Lox as namespace.name.
+type Namespace struct {
+	name    string
+	members map[string]any
+}
+
+func (ns *Namespace) String() string {
+	return "<namespace " + ns.name + ">"
+}
+
+// Define converts a Go value with ToLox and adds it to the namespace.
+func (ns *Namespace) Define(name string, value any) error {
+	converted, err := ToLox(value)
+	if err != nil {
+		return err
+	}
+	ns.members[name] = converted
+	return nil
+}
+
+// DefineNative adds a native function to the namespace.
+func (ns *Namespace) DefineNative(native *NativeFunction) {
+	ns.members[native.Name] = native
+}
+
+// Namespace returns the nested namespace with the given name, creating it if
+// needed.
+func (ns *Namespace) Namespace(name string) *Namespace {
+	if nested, ok := ns.members[name].(*Namespace); ok {
+		return nested
+	}
+	nested := &Namespace{ns.name + "." + name, make(map[string]any)}
+	ns.members[name] = nested
+	return nested
+}
+
+func (ns *Namespace) Get(in *Interpreter, name string) (any, error) {
+	if value, ok := ns.members[name]; ok {
+		return value, nil
+	}
+	return nil, fmt.Errorf("Undefined property '%s'.", name)
+}
+
+func (ns *Namespace) Set(in *Interpreter, name string, value any) error {
+	return errors.New("Only instances have fields.")
+}
+
+// DefineNative makes a native function available as a global.
+func (in *Interpreter) DefineNative(native *NativeFunction) {
+	in.globals.Define(native.Name, native)
+}
+
+// DefineNamespace returns the global namespace with the given name, creating
+// it if needed.
+func (in *Interpreter) DefineNamespace(name string) *Namespace {
+	if value, found := in.globals.Lookup(name); found {
+		if ns, ok := value.(*Namespace); ok {
+			return ns
+		}
+	}
+	ns := &Namespace{name, make(map[string]any)}
+	in.globals.Define(name, ns)
+	return ns
+}
+
+func defineBuiltins(in *Interpreter) {
+	in.DefineNative(&NativeFunction{
+		Name: "clock",
+		Fn: func(in *Interpreter, arguments []any) (any, error) {
+			return float64(time.Now().Unix()), nil
+		},
+	})
+}
@@ -0,0 +1,120 @@
+package lox
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestNativeFunctions(t *testing.T) {
+	in := NewInterpreter()
+	var out strings.Builder
+	in.Stdout = &out
+
+	mathNamespace := in.DefineNamespace("math")
+	mathNamespace.DefineNative(&NativeFunction{
+		Name:   "sqrt",
+		Params: []ParamType{PT_NUMBER},
+		Fn: func(in *Interpreter, arguments []any) (any, error) {
+			return math.Sqrt(arguments[0].(float64)), nil
+		},
+	})
+	in.DefineNative(&NativeFunction{
+		Name:     "join",
+		Params:   []ParamType{PT_STRING, PT_STRING},
+		Variadic: true,
+		Fn: func(in *Interpreter, arguments []any) (any, error) {
+			parts := make([]string, len(arguments)-1)
+			for i, argument := range arguments[1:] {
+				parts[i] = argument.(string)
+			}
+			return strings.Join(parts, arguments[0].(string)), nil
+		},
+	})
+	in.DefineNative(&NativeFunction{
+		Name: "three",
+		Fn: func(in *Interpreter, arguments []any) (any, error) {
+			return 3, nil
+		},
+	})
+	in.DefineNative(&NativeFunction{
+		Name: "words",
+		Fn: func(in *Interpreter, arguments []any) (any, error) {
+			return []string{"a", "b"}, nil
+		},
+	})
+	in.DefineNative(&NativeFunction{
+		Name: "channel",
+		Fn: func(in *Interpreter, arguments []any) (any, error) {
+			return make(chan int), nil
+		},
+	})
+	if err := mathNamespace.Define("answer", 42); err != nil {
+		t.Fatal(err)
+	}
+	if err := mathNamespace.Define("channel", make(chan int)); err == nil {
+		t.Error("expected an error defining a channel")
+	}
+	in.DefineNative(&NativeFunction{
+		Name: "fail",
+		Fn: func(in *Interpreter, arguments []any) (any, error) {
+			return nil, errors.New("Something went wrong.")
+		},
+	})
+
+	err := in.Run([]byte(`
+		print math.sqrt(16);
+		print join(", ");
+		print join(", ", "a", "b", "c");
+		print math.sqrt;
+		print math;
+		print three() == 3;
+		print three() + math.answer;
+		print words();
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "4\n\na, b, c\n<native fn>\n<namespace math>\ntrue\n45\n[a, b]\n"; out.String() != want {
+		t.Errorf("printed %q, want %q", out.String(), want)
+	}
+
+	for source, want := range map[string]string{
+		`math.sqrt("16");`:    "Expected number as argument 1 to 'sqrt' but got string.",
+		`math.sqrt(1, 2);`:    "Expected 1 arguments but got 2.",
+		`join();`:             "Expected at least 1 arguments but got 0.",
+		`join(", ", "a", 1);`: "Expected string as argument 3 to 'join' but got number.",
+		`fail();`:             "Something went wrong.",
+		`channel();`:          "Can't convert the result of 'channel': lox: value: can't convert Go value of type chan int to a Lox value",
+		`math.cbrt(8);`:       "Undefined property 'cbrt'.",
+	} {
+		var runtimeError *RuntimeError
+		if err := in.Run([]byte(source)); !errors.As(err, &runtimeError) || runtimeError.Message != want {
+			t.Errorf("%s gave %v, want %q", source, err, want)
+		}
+	}
+}
+
+func TestVariadicWithoutParams(t *testing.T) {
+	in := NewInterpreter()
+	var out strings.Builder
+	in.Stdout = &out
+	log := &NativeFunction{
+		Name:     "log",
+		Variadic: true,
+		Fn: func(in *Interpreter, arguments []any) (any, error) {
+			return float64(len(arguments)), nil
+		},
+	}
+	in.DefineNative(log)
+	if log.Arity() != 0 {
+		t.Errorf("arity is %d, want 0", log.Arity())
+	}
+	if err := in.Run([]byte(`print log(); print log(1, "two", nil);`)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "0\n3\n"; out.String() != want {
+		t.Errorf("printed %q, want %q", out.String(), want)
+	}
+}
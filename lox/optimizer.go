@@ -0,0 +1,311 @@
+package lox
+
+// The optimizer rewrites resolved statements into simpler ones that behave
+// the same. It folds operators applied to literals, drops branches and loops
+// whose condition is a literal that never lets them run, and flattens blocks
+// that declare nothing. Operations that fail at run time, such as "a" - 1,
+// are left alone so they still fail where they are.
+
+// Optimize rewrites resolved statements. It changes the nodes in place and
+// returns the statements that remain.
+func Optimize(statements []Stmt) []Stmt {
+	return optimizeStatements(statements)
+}
+
+func optimizeStatements(statements []Stmt) []Stmt {
+	optimized := make([]Stmt, 0, len(statements))
+	for _, statement := range statements {
+		statement = statement.Optimize()
+		if block, ok := statement.(*Block); ok && !declaresAnything(block.Statements) {
+			optimized = append(optimized, block.Statements...)
+		} else if statement != nil {
+			optimized = append(optimized, statement)
+		}
+	}
+	return optimized
+}
+
+func declaresAnything(statements []Stmt) bool {
+	for _, statement := range statements {
+		switch statement.(type) {
+		case *VarStatement, *FunctionDeclaration, *ClassDeclaration:
+			return true
+		}
+	}
+	return false
+}
+
+// optimizeBranch optimizes the branch of an if statement or the body of a
+// loop, which can't be left out.
+func optimizeBranch(statement Stmt) Stmt {
+	if statement = statement.Optimize(); statement == nil {
+		return &Block{}
+	}
+	return statement
+}
+
+func (s *PrintStatement) Optimize() Stmt {
+	s.Value = s.Value.Optimize()
+	return s
+}
+
+func (s *ExpressionStatement) Optimize() Stmt {
+	s.Expr = s.Expr.Optimize()
+	return s
+}
+
+func (s *VarStatement) Optimize() Stmt {
+	if s.Initializer != nil {
+		s.Initializer = s.Initializer.Optimize()
+	}
+	return s
+}
+
+func (b *Block) Optimize() Stmt {
+	b.Statements = optimizeStatements(b.Statements)
+	return b
+}
+
+func (s *IfStatement) Optimize() Stmt {
+	s.Condition = s.Condition.Optimize()
+	if condition, ok := s.Condition.(*Literal); ok {
+		if condition.value().isTruthy() {
+			return s.ThenBranch.Optimize()
+		} else if s.ElseBranch != nil {
+			return s.ElseBranch.Optimize()
+		}
+		return nil
+	}
+	s.ThenBranch = optimizeBranch(s.ThenBranch)
+	if s.ElseBranch != nil {
+		s.ElseBranch = s.ElseBranch.Optimize()
+	}
+	return s
+}
+
+func (w *WhileStatement) Optimize() Stmt {
+	w.Condition = w.Condition.Optimize()
+	if condition, ok := w.Condition.(*Literal); ok && !condition.value().isTruthy() {
+		return nil
+	}
+	w.Body = optimizeBranch(w.Body)
+	if w.Increment != nil {
+		w.Increment = w.Increment.Optimize()
+	}
+	return w
+}
+
+func (s *BreakStatement) Optimize() Stmt {
+	return s
+}
+
+func (s *ContinueStatement) Optimize() Stmt {
+	return s
+}
+
+func (f *FunctionDeclaration) Optimize() Stmt {
+	f.Body = optimizeStatements(f.Body)
+	return f
+}
+
+func (r *ReturnStatement) Optimize() Stmt {
+	if r.value != nil {
+		r.value = r.value.Optimize()
+	}
+	return r
+}
+
+func (c *ClassDeclaration) Optimize() Stmt {
+	for _, method := range c.Methods {
+		method.Optimize()
+	}
+	return c
+}
+
+// foldedLiteral makes a literal for the value an expression was folded to,
+// spanning the expression so errors involving it still point at the source.
+func foldedLiteral(value Value, span Span) *Literal {
+	token := &Token{
+		Str:    value.String(),
+		Line:   span.Start.Line,
+		Column: span.Start.Column,
+		Offset: span.Start.Offset,
+		Length: span.End.End() - span.Start.Offset,
+	}
+	switch value.kind {
+	case VK_NIL:
+		token.Type = NIL
+	case VK_BOOL:
+		token.Type = FALSE
+		if value.isTruthy() {
+			token.Type = TRUE
+		}
+	case VK_NUMBER:
+		token.Type, token.Content = NUMBER, value.number
+	case VK_STRING:
+		token.Type, token.Content = STRING, value.ref
+		token.Str = `"` + token.Str + `"`
+	}
+	return &Literal{token: token, span: span}
+}
+
+func (l *Literal) Optimize() Expr {
+	return l
+}
+
+func (g *Grouping) Optimize() Expr {
+	g.expr = g.expr.Optimize()
+	if literal, ok := g.expr.(*Literal); ok {
+		return foldedLiteral(literal.value(), g.Span())
+	}
+	return g
+}
+
+func (u *Unary) Optimize() Expr {
+	u.Expr = u.Expr.Optimize()
+	operand, ok := u.Expr.(*Literal)
+	if !ok {
+		return u
+	}
+	value := operand.value()
+	switch {
+	case u.Op.Type == BANG:
+		return foldedLiteral(boolValue(!value.isTruthy()), u.Span())
+	case u.Op.Type == MINUS && value.kind == VK_NUMBER:
+		return foldedLiteral(numberValue(-value.number), u.Span())
+	}
+	return u
+}
+
+func (b *Binary) Optimize() Expr {
+	b.Left, b.Right = b.Left.Optimize(), b.Right.Optimize()
+	left, lok := b.Left.(*Literal)
+	right, rok := b.Right.(*Literal)
+	if !lok || !rok {
+		return b
+	}
+	if result, ok := foldBinary(b.Op.Type, left.value(), right.value()); ok {
+		return foldedLiteral(result, b.Span())
+	}
+	return b
+}
+
+// foldBinary applies an operator to two values, unless doing so would raise
+// a runtime error.
+func foldBinary(op TokenType, left, right Value) (Value, bool) {
+	switch op {
+	case EQUAL_EQUAL:
+		return boolValue(left.equals(right)), true
+	case BANG_EQUAL:
+		return boolValue(!left.equals(right)), true
+	case PLUS:
+		if left.kind == VK_STRING && right.kind == VK_STRING {
+			return stringValue(left.str() + right.str()), true
+		}
+	}
+	if left.kind != VK_NUMBER || right.kind != VK_NUMBER {
+		return nilValue, false
+	}
+	switch op {
+	case PLUS:
+		return numberValue(left.number + right.number), true
+	case MINUS:
+		return numberValue(left.number - right.number), true
+	case STAR:
+		return numberValue(left.number * right.number), true
+	case SLASH:
+		return numberValue(left.number / right.number), true
+	case LESS:
+		return boolValue(left.number < right.number), true
+	case GREATER:
+		return boolValue(left.number > right.number), true
+	case LESS_EQUAL:
+		return boolValue(left.number <= right.number), true
+	case GREATER_EQUAL:
+		return boolValue(left.number >= right.number), true
+	}
+	return nilValue, false
+}
+
+func (v *Variable) Optimize() Expr {
+	return v
+}
+
+func (a *Assign) Optimize() Expr {
+	a.Value = a.Value.Optimize()
+	return a
+}
+
+func (l *Logical) Optimize() Expr {
+	l.left, l.right = l.left.Optimize(), l.right.Optimize()
+	return l
+}
+
+func (c *Call) Optimize() Expr {
+	c.callee = c.callee.Optimize()
+	for i, argument := range c.arguments {
+		c.arguments[i] = argument.Optimize()
+	}
+	return c
+}
+
+func (g *Get) Optimize() Expr {
+	g.object = g.object.Optimize()
+	return g
+}
+
+func (s *Set) Optimize() Expr {
+	s.object, s.value = s.object.Optimize(), s.value.Optimize()
+	return s
+}
+
+func (t *This) Optimize() Expr {
+	return t
+}
+
+func (s *Super) Optimize() Expr {
+	return s
+}
+
+func (l *List) Optimize() Expr {
+	for i, element := range l.elements {
+		l.elements[i] = element.Optimize()
+	}
+	return l
+}
+
+func (i *Index) Optimize() Expr {
+	i.object, i.index = i.object.Optimize(), i.index.Optimize()
+	return i
+}
+
+func (s *SetIndex) Optimize() Expr {
+	s.object, s.index, s.value = s.object.Optimize(), s.index.Optimize(), s.value.Optimize()
+	return s
+}
+
+// Optimize joins an interpolation whose parts are all literals into a
+// string.
+func (i *Interpolation) Optimize() Expr {
+	values := make([]Value, len(i.parts))
+	folded := true
+	for j, part := range i.parts {
+		i.parts[j] = part.Optimize()
+		if literal, ok := i.parts[j].(*Literal); ok {
+			values[j] = literal.value()
+		} else {
+			folded = false
+		}
+	}
+	if folded {
+		return foldedLiteral(interpolate(values), i.Span())
+	}
+	return i
+}
+
+func (m *Map) Optimize() Expr {
+	for i, key := range m.keys {
+		m.keys[i], m.values[i] = key.Optimize(), m.values[i].Optimize()
+	}
+	return m
+}
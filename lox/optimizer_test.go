@@ -0,0 +1,64 @@
+package lox
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConstantFolding(t *testing.T) {
+	tests := []struct {
+		source, want string
+	}{
+		{`(1 + 2) * 3`, `9.0`},
+		{`"a" + "b" == "ab"`, `true`},
+		{`!nil`, `true`},
+		{`-(2) < x`, `(< -2.0 (var IDENTIFIER x null))`},
+		{`"a" - 1`, `(- a 1.0)`},
+		{`-"a"`, `(- a)`},
+		{`"n = ${1 + 2}, ${nil}"`, `n = 3, nil`},
+		{`"x ${x}"`, `(interpolate x  (var IDENTIFIER x null))`},
+	}
+	for _, test := range tests {
+		tokens, _ := Tokenize([]byte(test.source))
+		expr, err := NewParser(tokens).ParseExpression()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := expr.Optimize().String(); got != test.want {
+			t.Errorf("%s optimized to %s, want %s", test.source, got, test.want)
+		}
+	}
+}
+
+func TestOptimizedPrograms(t *testing.T) {
+	source := `
+		if (false) print "dropped"; else { { print 1 + 1; } }
+		while (false) print "never";
+		{ var a = "kept"; { print a; } }
+		print "a" + "b";
+		print 1 +
+			(2 - "a");
+	`
+	tokens, _ := Tokenize([]byte(source))
+	statements, err := NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := NewInterpreter()
+	var out strings.Builder
+	in.Stdout = &out
+	if err := in.Resolve(statements); err != nil {
+		t.Fatal(err)
+	}
+	statements = Optimize(statements)
+	if len(statements) != 4 {
+		t.Errorf("expected 4 statements to remain, got %d", len(statements))
+	}
+	err = in.Execute(statements)
+	if got := out.String(); got != "2\nkept\nab\n" {
+		t.Errorf("printed %q", got)
+	}
+	if runtimeError, ok := err.(*RuntimeError); !ok || runtimeError.Token.Line != 7 {
+		t.Errorf("expected a runtime error on line 7, got %v", err)
+	}
+}
@@ -0,0 +1,619 @@
+package lox
+
+import "errors"
+
+// maxNesting limits how deeply the trees the parser builds can nest. The
+// parser, and the resolver, optimizer and compiler after it, walk them
+// recursively, so deeper code would exhaust the Go stack instead of failing
+// with an error. Every operator in a chain like 1 + 2 + 3 counts as a level.
+const maxNesting = 100000
+
+type Parser struct {
+	tokens  []Token
+	current int
+	errors  []error
+	// depth is the level of nesting of what is being parsed.
+	depth int
+}
+
+func NewParser(tokens []Token) *Parser {
+	return &Parser{tokens: tokens}
+}
+
+func (p *Parser) isAtEnd() bool {
+	return p.peek().Type == EOF
+}
+
+func (p *Parser) peek() *Token {
+	return &p.tokens[p.current]
+}
+
+func (p *Parser) previous() *Token {
+	return &p.tokens[p.current-1]
+}
+
+func (p *Parser) advance() *Token {
+	if !p.isAtEnd() {
+		p.current++
+	}
+	return p.previous()
+}
+
+func (p *Parser) check(t TokenType) bool {
+	if p.isAtEnd() {
+		return false
+	}
+	return p.peek().Type == t
+}
+
+func (p *Parser) match(types ...TokenType) bool {
+	for _, t := range types {
+		if p.check(t) {
+			p.advance()
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parser) consume(t TokenType, msg string) *Token {
+	if !p.check(t) {
+		p.error(p.peek(), msg)
+	}
+	return p.advance()
+}
+
+// error aborts the current declaration with a syntax error at the given token.
+// The panic is recovered by declaration, which records the error and skips
+// ahead to the next statement.
+func (p *Parser) error(token *Token, msg string) {
+	panic(&SyntaxError{token, msg})
+}
+
+// report records a syntax error that doesn't leave the parser confused, so
+// parsing can go on from where it is.
+func (p *Parser) report(token *Token, msg string) {
+	p.errors = append(p.errors, &SyntaxError{token, msg})
+}
+
+// nest enters one more level of nesting, failing at token past maxNesting.
+// Callers go back to the depth they started at when they return. After a
+// syntax error, declaration and ParseExpression reset it.
+func (p *Parser) nest(token *Token) {
+	p.depth++
+	if p.depth > maxNesting {
+		p.error(token, "Too much nesting.")
+	}
+}
+
+func (p *Parser) unnest(depth int) {
+	p.depth = depth
+}
+
+// recordSyntaxError records the value recovered from a panic raised by error.
+// Anything else is not ours to handle and keeps panicking.
+func (p *Parser) recordSyntaxError(r any) {
+	syntaxError, ok := r.(*SyntaxError)
+	if !ok {
+		panic(r)
+	}
+	p.errors = append(p.errors, syntaxError)
+}
+
+// synchronize discards tokens until it reaches what looks like the start of
+// the next statement.
+func (p *Parser) synchronize() {
+	p.advance()
+	for !p.isAtEnd() {
+		if p.previous().Type == SEMICOLON {
+			return
+		}
+		switch p.peek().Type {
+		case CLASS, FUN, VAR, FOR, IF, WHILE, PRINT, RETURN, BREAK, CONTINUE:
+			return
+		}
+		p.advance()
+	}
+}
+
+// Parse parses a whole program into a list of statements. Declarations with
+// syntax errors are left out, and all the errors found are returned joined
+// together.
+func (p *Parser) Parse() ([]Stmt, error) {
+	statements := []Stmt{}
+	for !p.isAtEnd() {
+		if statement := p.declaration(); statement != nil {
+			statements = append(statements, statement)
+		}
+	}
+	return statements, errors.Join(p.errors...)
+}
+
+// ParseExpression parses a single expression.
+func (p *Parser) ParseExpression() (expr Expr, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.recordSyntaxError(r)
+			p.depth = 0
+			expr = nil
+		}
+		err = errors.Join(p.errors...)
+	}()
+	return p.expression(), nil
+}
+
+func (p *Parser) declaration() (statement Stmt) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.recordSyntaxError(r)
+			p.synchronize()
+			statement = nil
+		}
+	}()
+	defer p.unnest(p.depth)
+	p.nest(p.peek())
+	if p.match(CLASS) {
+		return p.classDeclaration()
+	}
+	if p.match(FUN) {
+		return p.function("function")
+	}
+	if p.match(VAR) {
+		return p.varDeclaration()
+	}
+	return p.statement()
+}
+
+func (p *Parser) classDeclaration() Stmt {
+	name := p.consume(IDENTIFIER, "Expect class name.")
+	var superclass *Variable
+	if p.match(LESS) {
+		identifier := p.consume(IDENTIFIER, "Expect superclass name.")
+		superclass = &Variable{Name: identifier}
+	}
+	p.consume(LEFT_BRACE, "Expect '{' before class body.")
+	var methods []*FunctionDeclaration
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		methods = append(methods, p.function("method"))
+	}
+	p.consume(RIGHT_BRACE, "Expect '}' after class body.")
+	return &ClassDeclaration{Name: name, Superclass: superclass, Methods: methods}
+}
+
+func (p *Parser) varDeclaration() Stmt {
+	name := p.consume(IDENTIFIER, "Expect variable name.")
+
+	var initializer Expr
+	if p.match(EQUAL) {
+		initializer = p.expression()
+	}
+
+	p.consume(SEMICOLON, "Expect ';' after variable declaration.")
+	return &VarStatement{Name: name, Initializer: initializer}
+}
+
+func (p *Parser) whileStatement(label *Token) Stmt {
+	p.consume(LEFT_PAREN, "Expect '(' after 'while'.")
+	condition := p.expression()
+	p.consume(RIGHT_PAREN, "Expect ')' after condition.")
+	body := p.statement()
+	return &WhileStatement{Condition: condition, Body: body, Label: label}
+}
+
+// forStatement desugars a for loop into a while loop that keeps the
+// increment apart from the body, so continue can still run it.
+func (p *Parser) forStatement(label *Token) Stmt {
+	p.consume(LEFT_PAREN, "Expect '(' after 'for'.")
+	var initializer Stmt
+	if p.match(SEMICOLON) {
+		initializer = nil
+	} else if p.match(VAR) {
+		initializer = p.varDeclaration()
+	} else {
+		initializer = p.expressionStatement()
+	}
+	var condition Expr
+	if !p.check(SEMICOLON) {
+		condition = p.expression()
+	}
+	p.consume(SEMICOLON, "Expect ';' after loop condition.")
+	var increment Expr
+	if !p.check(RIGHT_PAREN) {
+		increment = p.expression()
+	}
+	p.consume(RIGHT_PAREN, "Expect ')' after for clauses.")
+	body := p.statement()
+	if condition == nil {
+		condition = &Literal{token: &Token{Type: TRUE}}
+	}
+	body = &WhileStatement{Condition: condition, Body: body, Increment: increment, Label: label}
+	if initializer != nil {
+		body = &Block{Statements: []Stmt{initializer, body}}
+	}
+	return body
+}
+
+func (p *Parser) statement() Stmt {
+	defer p.unnest(p.depth)
+	p.nest(p.peek())
+	if p.check(IDENTIFIER) && p.tokens[p.current+1].Type == COLON {
+		return p.labeledStatement()
+	}
+	if p.match(FOR) {
+		return p.forStatement(nil)
+	}
+	if p.match(IF) {
+		return p.ifStatement()
+	}
+	if p.match(PRINT) {
+		return p.printStatement()
+	}
+	if p.match(RETURN) {
+		return p.returnStatement()
+	}
+	if p.match(WHILE) {
+		return p.whileStatement(nil)
+	}
+	if p.match(BREAK) {
+		keyword, label := p.previous(), p.loopLabel()
+		p.consume(SEMICOLON, "Expect ';' after 'break'.")
+		return &BreakStatement{keyword: keyword, label: label}
+	}
+	if p.match(CONTINUE) {
+		keyword, label := p.previous(), p.loopLabel()
+		p.consume(SEMICOLON, "Expect ';' after 'continue'.")
+		return &ContinueStatement{keyword: keyword, label: label}
+	}
+	if p.check(LEFT_BRACE) && !p.startsMap() {
+		p.advance()
+		return &Block{Statements: p.block()}
+	}
+	return p.expressionStatement()
+}
+
+// labeledStatement parses a loop with a label, written "name:" before it.
+func (p *Parser) labeledStatement() Stmt {
+	label := p.advance()
+	p.advance()
+	if p.match(FOR) {
+		return p.forStatement(label)
+	}
+	if p.match(WHILE) {
+		return p.whileStatement(label)
+	}
+	p.error(p.peek(), "Expect loop after label.")
+	return nil
+}
+
+// loopLabel parses the label after break or continue, if there is one.
+func (p *Parser) loopLabel() *Token {
+	if p.match(IDENTIFIER) {
+		return p.previous()
+	}
+	return nil
+}
+
+// startsMap tells whether the brace at the start of a statement opens a map
+// literal rather than a block: it does when it's followed by a literal key
+// and a colon. Other maps need parentheses there, since "{ name:" starts a
+// block with a labeled loop.
+func (p *Parser) startsMap() bool {
+	switch p.tokens[p.current+1].Type {
+	case STRING, NUMBER, TRUE, FALSE, NIL:
+		return p.tokens[p.current+2].Type == COLON
+	}
+	return false
+}
+
+func (p *Parser) ifStatement() Stmt {
+	p.consume(LEFT_PAREN, "Expect '(' after 'if'.")
+	condition := p.expression()
+	p.consume(RIGHT_PAREN, "Expect ')' after if condition.")
+	thenBranch := p.statement()
+	var elseBranch Stmt
+	if p.match(ELSE) {
+		elseBranch = p.statement()
+	}
+	return &IfStatement{condition, thenBranch, elseBranch}
+}
+
+func (p *Parser) printStatement() Stmt {
+	expr := p.expression()
+	p.consume(SEMICOLON, "Expect ';' after value.")
+	return &PrintStatement{expr}
+}
+
+func (p *Parser) expressionStatement() Stmt {
+	expr := p.expression()
+	p.consume(SEMICOLON, "Expect ';' after expression.")
+	return &ExpressionStatement{expr}
+}
+
+func (p *Parser) function(kind string) *FunctionDeclaration {
+	name := p.consume(IDENTIFIER, "Expect "+kind+" name.")
+	p.consume(LEFT_PAREN, "Expect '(' after "+kind+" name.")
+	parameters := []*Token{}
+	if !p.check(RIGHT_PAREN) {
+		for {
+			if len(parameters) > 255 {
+				p.report(p.peek(), "Can't have more than 255 parameters.")
+			}
+			parameters = append(parameters, p.consume(IDENTIFIER, "Expect parameter name."))
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	p.consume(RIGHT_PAREN, "Expect ')' after parameters.")
+	p.consume(LEFT_BRACE, "Expect '{' before "+kind+" body.")
+	body := p.block()
+	return &FunctionDeclaration{Name: name, Params: parameters, Body: body}
+}
+
+func (p *Parser) block() []Stmt {
+	statements := []Stmt{}
+	for !p.isAtEnd() && !p.check(RIGHT_BRACE) {
+		if statement := p.declaration(); statement != nil {
+			statements = append(statements, statement)
+		}
+	}
+	p.consume(RIGHT_BRACE, "Expect '}' after block.")
+	return statements
+}
+
+func (p *Parser) returnStatement() Stmt {
+	keyword := p.previous()
+	var value Expr
+	if !p.check(SEMICOLON) {
+		value = p.expression()
+	}
+	p.consume(SEMICOLON, "Expect ';' after return value.")
+	return &ReturnStatement{keyword, value}
+}
+
+func (p *Parser) expression() Expr {
+	return p.assignment()
+}
+
+func (p *Parser) assignment() Expr {
+	depth := p.depth
+	p.nest(p.peek())
+	expr := p.or()
+	if p.match(EQUAL) {
+		equals := p.previous()
+		value := p.assignment()
+		p.depth = depth
+		if name, ok := expr.(*Variable); ok {
+			return &Assign{name, value}
+		} else if get, ok := expr.(*Get); ok {
+			return &Set{get.object, get.name, value}
+		} else if index, ok := expr.(*Index); ok {
+			return &SetIndex{index.object, index.index, index.bracket, value}
+		}
+		p.report(equals, "Invalid assignment target.")
+	}
+	p.depth = depth
+	return expr
+}
+
+func (p *Parser) or() Expr {
+	depth := p.depth
+	expr := p.and()
+	for p.match(OR) {
+		operator := p.previous()
+		p.nest(operator)
+		right := p.and()
+		expr = &Logical{expr, operator, right}
+	}
+	p.depth = depth
+	return expr
+}
+
+func (p *Parser) and() Expr {
+	depth := p.depth
+	expr := p.equality()
+	for p.match(AND) {
+		operator := p.previous()
+		p.nest(operator)
+		right := p.and()
+		expr = &Logical{expr, operator, right}
+	}
+	p.depth = depth
+	return expr
+}
+
+func (p *Parser) primary() Expr {
+	if continuesString(p.peek()) {
+		p.error(p.peek(), "Expected expression.")
+	}
+	if p.match(NIL, TRUE, FALSE, NUMBER, STRING) {
+		return &Literal{token: p.previous()}
+	}
+	if p.match(INTERPOLATION) {
+		return p.interpolation()
+	}
+	if p.match(SUPER) {
+		keyword := p.previous()
+		p.consume(DOT, "Expect '.' after 'super'.")
+		method := p.consume(IDENTIFIER, "Expect superclass method name.")
+		return &Super{keyword: keyword, method: method}
+	}
+	if p.match(LEFT_PAREN) {
+		paren := p.previous()
+		expr := p.expression()
+		if expr == nil {
+			p.error(p.peek(), "Expected expression.")
+		}
+		closing := p.consume(RIGHT_PAREN, "Expect ')' after expression.")
+		return &Grouping{paren, expr, closing}
+	}
+	if p.match(LEFT_BRACKET) {
+		bracket := p.previous()
+		elements := []Expr{}
+		for !p.check(RIGHT_BRACKET) {
+			elements = append(elements, p.expression())
+			if !p.match(COMMA) {
+				break
+			}
+		}
+		closing := p.consume(RIGHT_BRACKET, "Expect ']' after list elements.")
+		return &List{bracket, elements, closing}
+	}
+	if p.match(LEFT_BRACE) {
+		brace := p.previous()
+		var keys, values []Expr
+		for !p.check(RIGHT_BRACE) {
+			keys = append(keys, p.expression())
+			p.consume(COLON, "Expect ':' after map key.")
+			values = append(values, p.expression())
+			if !p.match(COMMA) {
+				break
+			}
+		}
+		closing := p.consume(RIGHT_BRACE, "Expect '}' after map entries.")
+		return &Map{brace, keys, values, closing}
+	}
+	if p.match(THIS) {
+		return &This{keyword: p.previous()}
+	}
+	if p.match(IDENTIFIER) {
+		return &Variable{Name: p.previous()}
+	}
+	p.error(p.peek(), "Expected expression.")
+	return nil
+}
+
+// interpolation parses the rest of a string with embedded expressions,
+// after its first INTERPOLATION token. The tokenizer ends every segment of
+// the string but the last with "${", and starts every one but the first with
+// the '}' ending the expression before it.
+func (p *Parser) interpolation() Expr {
+	start := p.previous()
+	expr := &Interpolation{start: start}
+	for segment := start; ; segment = p.previous() {
+		if text := segment.Content.(string); text != "" {
+			literal := *segment
+			literal.Type = STRING
+			expr.parts = append(expr.parts, &Literal{token: &literal})
+		}
+		if segment.Type == STRING {
+			expr.end = segment
+			return expr
+		}
+		expr.parts = append(expr.parts, p.expression())
+		if next := p.peek(); !continuesString(next) {
+			p.error(next, "Expect '}' after interpolated expression.")
+		}
+		p.advance()
+	}
+}
+
+// continuesString reports whether a token is a segment of a string that
+// follows an embedded expression, rather than the start of a string.
+func continuesString(token *Token) bool {
+	return (token.Type == STRING || token.Type == INTERPOLATION) && token.Str[0] == '}'
+}
+
+func (p *Parser) unary() Expr {
+	if p.match(MINUS, BANG) {
+		op := p.previous()
+		p.nest(op)
+		expr := p.unary()
+		p.depth--
+		return &Unary{op, expr}
+	}
+	return p.call()
+}
+
+func (p *Parser) call() Expr {
+	depth := p.depth
+	expr := p.primary()
+	for {
+		if p.match(LEFT_PAREN) {
+			p.nest(p.previous())
+			expr = p.finishCall(expr)
+		} else if p.match(DOT) {
+			p.nest(p.previous())
+			name := p.consume(IDENTIFIER, "Expect property name after '.'.")
+			expr = &Get{object: expr, name: name}
+		} else if p.match(LEFT_BRACKET) {
+			p.nest(p.previous())
+			index := p.expression()
+			bracket := p.consume(RIGHT_BRACKET, "Expect ']' after index.")
+			expr = &Index{expr, index, bracket}
+		} else {
+			break
+		}
+	}
+	p.depth = depth
+	return expr
+}
+
+func (p *Parser) finishCall(callee Expr) Expr {
+	arguments := []Expr{}
+	if !p.check(RIGHT_PAREN) {
+		for {
+			if len(arguments) > 255 {
+				p.report(p.peek(), "Can't have more than 255 arguments.")
+			}
+			arguments = append(arguments, p.expression())
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	paren := p.consume(RIGHT_PAREN, "Expect ')' after arguments.")
+	return &Call{callee: callee, paren: paren, arguments: arguments}
+}
+
+func (p *Parser) factor() Expr {
+	depth := p.depth
+	left := p.unary()
+	for p.match(STAR, SLASH) {
+		op := p.previous()
+		p.nest(op)
+		right := p.unary()
+		left = &Binary{op, left, right}
+	}
+	p.depth = depth
+	return left
+}
+
+func (p *Parser) term() Expr {
+	depth := p.depth
+	left := p.factor()
+	for p.match(PLUS, MINUS) {
+		op := p.previous()
+		p.nest(op)
+		right := p.factor()
+		left = &Binary{op, left, right}
+	}
+	p.depth = depth
+	return left
+}
+
+func (p *Parser) comparison() Expr {
+	depth := p.depth
+	left := p.term()
+	for p.match(LESS, GREATER, LESS_EQUAL, GREATER_EQUAL) {
+		op := p.previous()
+		p.nest(op)
+		right := p.term()
+		left = &Binary{op, left, right}
+	}
+	p.depth = depth
+	return left
+}
+
+func (p *Parser) equality() Expr {
+	depth := p.depth
+	left := p.comparison()
+	for p.match(EQUAL_EQUAL, BANG_EQUAL) {
+		op := p.previous()
+		p.nest(op)
+		right := p.comparison()
+		left = &Binary{op, left, right}
+	}
+	p.depth = depth
+	return left
+}
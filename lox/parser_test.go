@@ -0,0 +1,87 @@
+package lox
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGrouping(t *testing.T) {
+	tokens, _ := Tokenize([]byte("()"))
+	parser := NewParser(tokens)
+	_, err := parser.Parse()
+	var syntaxError *SyntaxError
+	if !errors.As(err, &syntaxError) {
+		t.Fatalf("expected a syntax error, got %v", err)
+	}
+	if syntaxError.Token.Type != RIGHT_PAREN {
+		t.Errorf("expected the error at ')', got %v", syntaxError.Token)
+	}
+}
+
+func TestParserRecovery(t *testing.T) {
+	tokens, _ := Tokenize([]byte("var a = ;\nprint a;\nvar b = 1 +;\n{ print (; }\nprint b;"))
+	statements, err := NewParser(tokens).Parse()
+	want := "[line 1] Error at ';': Expected expression.\n" +
+		"[line 3] Error at ';': Expected expression.\n" +
+		"[line 4] Error at ';': Expected expression."
+	if err == nil || err.Error() != want {
+		t.Errorf("got errors %q, want %q", err, want)
+	}
+	if len(statements) != 3 {
+		t.Errorf("expected the 3 valid statements to be kept, got %d", len(statements))
+	}
+}
+
+func TestInterpolationErrors(t *testing.T) {
+	for source, want := range map[string]string{
+		`print "a ${}";`:     "[line 1] Error at '}\"': Expected expression.",
+		`print "${1 + }";`:   "[line 1] Error at '}\"': Expected expression.",
+		`print "a ${1 2}";`:  "[line 1] Error at '2': Expect '}' after interpolated expression.",
+		`print "x${"${}"}";`: "[line 1] Error at '}\"': Expected expression.",
+	} {
+		tokens, _ := Tokenize([]byte(source))
+		if _, err := NewParser(tokens).Parse(); err == nil || err.Error() != want {
+			t.Errorf("%s gave %v, want %q", source, err, want)
+		}
+	}
+
+	// A string left open is reported alone, without the errors the parser
+	// finds at the end of the source it swallowed.
+	for source, want := range map[string]string{
+		`print "open ${1 + 2";`:         "[line 1] Error: Unterminated string.",
+		"var x = 1;\nprint \"a ${x} b;": "[line 2] Error: Unterminated string.",
+	} {
+		if err := NewInterpreter().Run([]byte(source)); err == nil || err.Error() != want {
+			t.Errorf("%q gave %v, want %q", source, err, want)
+		}
+	}
+}
+
+func TestNestingLimit(t *testing.T) {
+	for _, source := range []string{
+		"print " + strings.Repeat("(", maxNesting) + "1" + strings.Repeat(")", maxNesting) + ";",
+		"print 1" + strings.Repeat(" + 1", maxNesting) + ";",
+		strings.Repeat("{", maxNesting) + strings.Repeat("}", maxNesting),
+		"print " + strings.Repeat("-", maxNesting) + "1;",
+	} {
+		tokens, _ := Tokenize([]byte(source))
+		_, err := NewParser(tokens).Parse()
+		var syntaxError *SyntaxError
+		if !errors.As(err, &syntaxError) || syntaxError.Message != "Too much nesting." {
+			t.Errorf("%.20s... gave %v, want too much nesting", source, err)
+		}
+	}
+
+	// Chains well within the limit run on every backend.
+	source := "print 1" + strings.Repeat(" + 1", 20000) + ";"
+	for _, useVM := range []bool{false, true} {
+		in := NewInterpreter()
+		in.UseVM, in.Optimize = useVM, useVM
+		var out strings.Builder
+		in.Stdout = &out
+		if err := in.Run([]byte(source)); err != nil || out.String() != "20001\n" {
+			t.Errorf("printed %q, %v", out.String(), err)
+		}
+	}
+}
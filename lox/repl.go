@@ -0,0 +1,159 @@
+package lox
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Session runs the entries typed into an interactive prompt against one
+// interpreter, so globals and resolved functions persist from one entry to
+// the next. Entries are appended to a single source text, which keeps the
+// diagnostics about code from earlier entries pointing at the right place.
+type Session struct {
+	Interpreter *Interpreter
+	source      []byte
+	line        int
+}
+
+func NewSession(in *Interpreter) *Session {
+	return &Session{Interpreter: in, line: 1}
+}
+
+// Source returns the text of every entry run so far, for FormatError.
+func (s *Session) Source() []byte {
+	return s.source
+}
+
+// Incomplete reports whether the entry needs more lines before it can run,
+// because it has unclosed braces, brackets or parentheses or an unterminated
+// string.
+func (s *Session) Incomplete(entry string) bool {
+	tokens, err := Tokenize([]byte(entry))
+	depth := 0
+	for _, token := range tokens {
+		switch token.Type {
+		case LEFT_PAREN, LEFT_BRACE, LEFT_BRACKET:
+			depth++
+		case RIGHT_PAREN, RIGHT_BRACE, RIGHT_BRACKET:
+			depth--
+		}
+	}
+	return depth > 0 || hasUnterminatedString(err)
+}
+
+func hasUnterminatedString(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return slices.ContainsFunc(joined.Unwrap(), hasUnterminatedString)
+	}
+	syntaxError, ok := err.(*SyntaxError)
+	return ok && syntaxError.Message == "Unterminated string."
+}
+
+// Eval runs an entry. An entry made of a single expression without a
+// trailing semicolon is evaluated and its value is returned formatted the
+// way print shows it, with echo set.
+func (s *Session) Eval(entry string) (result string, echo bool, err error) {
+	start := len(s.source)
+	s.source = append(s.source, entry...)
+	s.source = append(s.source, '\n')
+	tokens, tokenizeErr := tokenize(s.source, start, s.line)
+	s.line = tokens[len(tokens)-1].Line
+	if tokenizeErr != nil {
+		return "", false, tokenizeErr
+	}
+
+	in := s.Interpreter
+	if expr := parseBareExpression(tokens); expr != nil {
+		value, err := in.Evaluate(expr)
+		if err != nil {
+			return "", false, err
+		}
+		return Stringify(value), true, nil
+	}
+
+	statements, err := NewParser(tokens).Parse()
+	if err != nil {
+		return "", false, errors.Join(err, resolveErrors(statements))
+	}
+	if err := in.Resolve(statements); err != nil {
+		return "", false, err
+	}
+	return "", false, in.Execute(statements)
+}
+
+// parseBareExpression returns the expression the tokens hold if they are
+// nothing but one expression, or nil otherwise.
+func parseBareExpression(tokens []Token) Expr {
+	if len(tokens) < 2 || tokens[len(tokens)-2].Type == SEMICOLON {
+		return nil
+	}
+	parser := NewParser(tokens)
+	expr, err := parser.ParseExpression()
+	if err != nil || !parser.isAtEnd() {
+		return nil
+	}
+	return expr
+}
+
+// Completions returns the candidates for completing the identifier or
+// property access path at the end of text. Global names and keywords are
+// offered for identifiers, and fields and methods of the instance the path
+// leads to are offered after a dot.
+func (s *Session) Completions(text string) []string {
+	start := len(text)
+	for start > 0 {
+		ch, size := utf8.DecodeLastRuneInString(text[:start])
+		if ch != '.' && !IsIdentifierPart(ch) {
+			break
+		}
+		start -= size
+	}
+	path := strings.Split(text[start:], ".")
+	partial := path[len(path)-1]
+
+	var names []string
+	if len(path) == 1 {
+		names = s.Interpreter.GlobalNames()
+		for keyword := range reservedKeywords {
+			names = append(names, keyword)
+		}
+	} else {
+		value, found := s.Interpreter.Global(path[0])
+		for _, name := range path[1 : len(path)-1] {
+			switch object := value.(type) {
+			case *LoxInstance:
+				value, found = object.fields[name]
+			case *Namespace:
+				value, found = object.members[name]
+			default:
+				found = false
+			}
+			if !found {
+				return nil
+			}
+		}
+		switch object := value.(type) {
+		case *LoxInstance:
+			names = object.members()
+		case HostInstance:
+			names = object.members()
+		case *Namespace:
+			for name := range object.members {
+				names = append(names, name)
+			}
+		}
+	}
+
+	prefix := text[:len(text)-len(partial)]
+	var candidates []string
+	for _, name := range names {
+		if strings.HasPrefix(name, partial) {
+			candidates = append(candidates, prefix+name)
+		}
+	}
+	sort.Strings(candidates)
+	return slices.Compact(candidates)
+}
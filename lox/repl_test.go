@@ -0,0 +1,65 @@
+package lox
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	in := NewInterpreter()
+	var out strings.Builder
+	in.Stdout = &out
+	session := NewSession(in)
+
+	for _, entry := range []string{"fun f(x) {", "(1 + ", "var xs = [1,", "print \"open", "print `raw"} {
+		if !session.Incomplete(entry) {
+			t.Errorf("%q should be incomplete", entry)
+		}
+	}
+	if session.Incomplete("fun f(x) {\n  return x;\n}") {
+		t.Error("a closed function should be complete")
+	}
+
+	steps := []struct {
+		entry  string
+		result string
+		echo   bool
+	}{
+		{"var count = 2;", "", false},
+		{"class Box { init() { this.size = 3; } area() { return this.size * this.size; } }", "", false},
+		{"var box = Box();", "", false},
+		{"count * 10", "20", true},
+		{"box.area()", "9", true},
+		{"print count;", "", false},
+	}
+	for _, step := range steps {
+		result, echo, err := session.Eval(step.entry)
+		if err != nil {
+			t.Fatalf("%q: %v", step.entry, err)
+		}
+		if result != step.result || echo != step.echo {
+			t.Errorf("%q gave %q (echo %v), want %q (echo %v)", step.entry, result, echo, step.result, step.echo)
+		}
+	}
+	if out.String() != "2\n" {
+		t.Errorf("printed %q", out.String())
+	}
+
+	if _, _, err := session.Eval("box.area() - \"x\""); err == nil {
+		t.Error("expected a runtime error")
+	}
+	if result, _, err := session.Eval("count"); err != nil || result != "2" {
+		t.Errorf("session did not survive the error: %q, %v", result, err)
+	}
+
+	if got, want := session.Completions("print bo"), []string{"print box"}; !slices.Equal(got, want) {
+		t.Errorf("got completions %q, want %q", got, want)
+	}
+	if got, want := session.Completions("box."), []string{"box.area", "box.init", "box.size"}; !slices.Equal(got, want) {
+		t.Errorf("got completions %q, want %q", got, want)
+	}
+	if got, want := session.Completions("cl"), []string{"class", "clock"}; !slices.Equal(got, want) {
+		t.Errorf("got completions %q, want %q", got, want)
+	}
+}
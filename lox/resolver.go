@@ -0,0 +1,459 @@
+package lox
+
+type FunctionType uint8
+
+const (
+	FT_NONE FunctionType = iota
+	FT_FUNCTION
+	FT_INITIALIZER
+	FT_METHOD
+)
+
+type ClassType uint8
+
+const (
+	CT_NONE ClassType = iota
+	CT_CLASS
+	CT_SUBCLASS
+)
+
+// Resolver walks the syntax tree before execution and binds each variable to
+// its declaration. Locals get a slot in the frame of the function declaring
+// them, and the variables a function captures from the functions around it
+// are listed in its declaration, like the upvalues of the VM. Globals get a
+// slot in the interpreter's globals. The bindings are stored in the nodes, so
+// resolved statements belong to the interpreter that resolved them.
+type Resolver struct {
+	interpreter     *Interpreter
+	function        *functionScope
+	currentFunction FunctionType
+	currentClass    ClassType
+	errors          []error
+	// locals are the bindings of local variables resolved so far. Whether a
+	// local is captured by a closure is only known once its whole scope has
+	// been resolved, so they are settled at the end.
+	locals []localBinding
+	// loops are the loops of the current function around the code being
+	// resolved, innermost last.
+	loops []*WhileStatement
+	// strings holds the string literals and property names of the program
+	// being resolved; see intern.
+	strings map[string]string
+}
+
+// functionScope holds the block scopes of a function being resolved. The
+// top-level code has one too, whose blocks' locals live in the script frame.
+type functionScope struct {
+	enclosing *functionScope
+	scopes    []scope
+	// slots is the number of frame slots given out so far.
+	slots    int
+	upvalues []upvalue
+}
+
+// scope maps the names declared in a block or a function to their slots.
+type scope map[string]*declared
+
+type declared struct {
+	slot     int
+	defined  bool
+	captured bool
+}
+
+type localBinding struct {
+	binding  *Binding
+	variable *declared
+}
+
+func NewResolver(interpreter *Interpreter) *Resolver {
+	return &Resolver{interpreter: interpreter, function: &functionScope{}}
+}
+
+// error records a resolve error at the given token. Resolution goes on so all
+// the errors in a program are reported at once.
+func (r *Resolver) error(token *Token, msg string) {
+	r.errors = append(r.errors, &ResolveError{token, msg})
+}
+
+// resolve resolves top-level statements and returns the number of slots
+// their frame needs.
+func (r *Resolver) resolve(statements []Stmt) int {
+	r.function.slots = 0
+	r.strings = make(map[string]string)
+	r.resolveStatements(statements)
+	// The strings are only shared within a program, so that a long-lived
+	// interpreter doesn't hold on to those of every program it ran.
+	r.strings = nil
+	for _, local := range r.locals {
+		if local.variable.captured {
+			local.binding.Kind = BK_CELL
+		}
+	}
+	r.locals = r.locals[:0]
+	return r.function.slots
+}
+
+// intern returns the first copy of a string met in the program being
+// resolved, so that equal literals and names share their text and compare
+// quickly.
+func (r *Resolver) intern(s string) string {
+	if interned, found := r.strings[s]; found {
+		return interned
+	}
+	r.strings[s] = s
+	return s
+}
+
+func (r *Resolver) beginScope() {
+	r.function.scopes = append(r.function.scopes, make(scope))
+}
+
+func (r *Resolver) endScope() {
+	r.function.scopes = r.function.scopes[:len(r.function.scopes)-1]
+}
+
+func (r *Resolver) currentScope() scope {
+	if len(r.function.scopes) == 0 {
+		return nil
+	}
+	return r.function.scopes[len(r.function.scopes)-1]
+}
+
+// declare adds a variable to the current scope and binds it to its slot.
+func (r *Resolver) declare(token *Token, binding *Binding) {
+	scope := r.currentScope()
+	if scope == nil {
+		*binding = Binding{BK_GLOBAL, r.interpreter.globals.slot(token.Str)}
+		return
+	}
+	variable, found := scope[token.Str]
+	if found {
+		r.error(token, "Already a variable with this name in this scope.")
+		variable.defined = false
+	} else {
+		variable = &declared{slot: r.function.slots}
+		r.function.slots++
+		scope[token.Str] = variable
+	}
+	r.bindLocal(binding, variable)
+}
+
+// declareHidden declares a variable the program can't declare itself, such
+// as this.
+func (r *Resolver) declareHidden(name string, binding *Binding) {
+	variable := &declared{slot: r.function.slots, defined: true}
+	r.function.slots++
+	r.currentScope()[name] = variable
+	r.bindLocal(binding, variable)
+}
+
+func (r *Resolver) define(token *Token) {
+	if scope := r.currentScope(); scope != nil {
+		scope[token.Str].defined = true
+	}
+}
+
+func (r *Resolver) bindLocal(binding *Binding, variable *declared) {
+	*binding = Binding{BK_LOCAL, variable.slot}
+	r.locals = append(r.locals, localBinding{binding, variable})
+}
+
+// resolveVariable binds a variable to its innermost declaration, taking it to
+// be a global if no scope declares it.
+func (r *Resolver) resolveVariable(name string, binding *Binding) {
+	if variable := r.function.lookUp(name); variable != nil {
+		r.bindLocal(binding, variable)
+	} else if index := r.function.resolveUpvalue(name); index != -1 {
+		*binding = Binding{BK_UPVALUE, index}
+	} else {
+		*binding = Binding{BK_GLOBAL, r.interpreter.globals.slot(name)}
+	}
+}
+
+func (f *functionScope) lookUp(name string) *declared {
+	for i := len(f.scopes) - 1; i >= 0; i-- {
+		if variable, found := f.scopes[i][name]; found {
+			return variable
+		}
+	}
+	return nil
+}
+
+// resolveUpvalue finds a variable declared by one of the enclosing
+// functions and returns its index among the variables the function captures.
+func (f *functionScope) resolveUpvalue(name string) int {
+	if f.enclosing == nil {
+		return -1
+	}
+	if variable := f.enclosing.lookUp(name); variable != nil {
+		variable.captured = true
+		return f.addUpvalue(variable.slot, true)
+	}
+	if index := f.enclosing.resolveUpvalue(name); index != -1 {
+		return f.addUpvalue(index, false)
+	}
+	return -1
+}
+
+func (f *functionScope) addUpvalue(index int, isLocal bool) int {
+	for i, upvalue := range f.upvalues {
+		if upvalue.index == index && upvalue.isLocal == isLocal {
+			return i
+		}
+	}
+	f.upvalues = append(f.upvalues, upvalue{index, isLocal})
+	return len(f.upvalues) - 1
+}
+
+func (r *Resolver) resolveStatements(statements []Stmt) {
+	for _, statement := range statements {
+		statement.Resolve(r)
+	}
+}
+
+func (b *Block) Resolve(r *Resolver) {
+	r.beginScope()
+	r.resolveStatements(b.Statements)
+	r.endScope()
+}
+
+func (s *VarStatement) Resolve(r *Resolver) {
+	r.declare(s.Name, &s.binding)
+	if s.Initializer != nil {
+		s.Initializer.Resolve(r)
+	}
+	r.define(s.Name)
+}
+
+func (v *Variable) Resolve(r *Resolver) {
+	if scope := r.currentScope(); scope != nil {
+		if variable, found := scope[v.Name.Str]; found && !variable.defined {
+			r.error(v.Name, "Can't read local variable in its own initializer.")
+		}
+	}
+	r.resolveVariable(v.Name.Str, &v.binding)
+}
+
+func (a *Assign) Resolve(r *Resolver) {
+	a.Value.Resolve(r)
+	r.resolveVariable(a.Name.Name.Str, &a.Name.binding)
+}
+
+func (f *FunctionDeclaration) Resolve(r *Resolver) {
+	r.declare(f.Name, &f.binding)
+	r.define(f.Name)
+	r.resolveFunction(f, FT_FUNCTION)
+}
+
+func (r *Resolver) resolveFunction(f *FunctionDeclaration, functionType FunctionType) {
+	enclosingFunction, enclosingLoops := r.currentFunction, r.loops
+	r.currentFunction, r.loops = functionType, nil
+	r.function = &functionScope{enclosing: r.function}
+	r.beginScope()
+	if functionType == FT_METHOD || functionType == FT_INITIALIZER {
+		r.declareHidden("this", &f.this)
+	}
+	f.params = make([]Binding, len(f.Params))
+	for i, param := range f.Params {
+		r.declare(param, &f.params[i])
+		r.define(param)
+	}
+	r.resolveStatements(f.Body)
+	r.endScope()
+	f.size, f.upvalues = r.function.slots, r.function.upvalues
+	r.function = r.function.enclosing
+	r.currentFunction, r.loops = enclosingFunction, enclosingLoops
+}
+
+func (s *ExpressionStatement) Resolve(r *Resolver) {
+	s.Expr.Resolve(r)
+}
+
+func (s *IfStatement) Resolve(r *Resolver) {
+	s.Condition.Resolve(r)
+	s.ThenBranch.Resolve(r)
+	if s.ElseBranch != nil {
+		s.ElseBranch.Resolve(r)
+	}
+}
+
+func (s *PrintStatement) Resolve(r *Resolver) {
+	s.Value.Resolve(r)
+}
+
+func (s *ReturnStatement) Resolve(r *Resolver) {
+	if r.currentFunction == FT_NONE {
+		r.error(s.keyword, "Can't return from top-level code.")
+	}
+	if s.value != nil {
+		if r.currentFunction == FT_INITIALIZER {
+			r.error(s.keyword, "Can't return a value from an initializer.")
+		}
+		s.value.Resolve(r)
+		if call, ok := s.value.(*Call); ok && r.currentFunction != FT_NONE {
+			call.tail = true
+		}
+	}
+}
+
+func (w *WhileStatement) Resolve(r *Resolver) {
+	w.Condition.Resolve(r)
+	r.loops = append(r.loops, w)
+	w.Body.Resolve(r)
+	r.loops = r.loops[:len(r.loops)-1]
+	if w.Increment != nil {
+		w.Increment.Resolve(r)
+	}
+}
+
+func (s *BreakStatement) Resolve(r *Resolver) {
+	s.loop = r.resolveLoop(s.keyword, s.label)
+}
+
+func (s *ContinueStatement) Resolve(r *Resolver) {
+	s.loop = r.resolveLoop(s.keyword, s.label)
+}
+
+// resolveLoop finds the loop a break or continue statement applies to: the
+// innermost one in the function, or the one with the label.
+func (r *Resolver) resolveLoop(keyword, label *Token) *WhileStatement {
+	if len(r.loops) == 0 {
+		r.error(keyword, "Can't use '"+keyword.Str+"' outside of a loop.")
+		return nil
+	}
+	if label == nil {
+		return r.loops[len(r.loops)-1]
+	}
+	for i := len(r.loops) - 1; i >= 0; i-- {
+		if loop := r.loops[i]; loop.Label != nil && loop.Label.Str == label.Str {
+			return loop
+		}
+	}
+	r.error(label, "No loop labeled '"+label.Str+"' around this one.")
+	return nil
+}
+
+func (c *ClassDeclaration) Resolve(r *Resolver) {
+	enclosingClass := r.currentClass
+	r.currentClass = CT_CLASS
+	r.declare(c.Name, &c.binding)
+	r.define(c.Name)
+	if c.Superclass != nil {
+		r.currentClass = CT_SUBCLASS
+		if c.Name.Str == c.Superclass.Name.Str {
+			r.error(c.Superclass.Name, "A class can't inherit from itself.")
+		} else {
+			c.Superclass.Resolve(r)
+		}
+	}
+	if c.Superclass != nil {
+		r.beginScope()
+		r.declareHidden("super", &c.super)
+	}
+	for _, method := range c.Methods {
+		method.Name.Str = r.intern(method.Name.Str)
+		functionType := FT_METHOD
+		if method.Name.Str == "init" {
+			functionType = FT_INITIALIZER
+		}
+		r.resolveFunction(method, functionType)
+	}
+	if c.Superclass != nil {
+		r.endScope()
+	}
+	r.currentClass = enclosingClass
+}
+
+func (b *Binary) Resolve(r *Resolver) {
+	b.Left.Resolve(r)
+	b.Right.Resolve(r)
+}
+
+func (c *Call) Resolve(r *Resolver) {
+	c.callee.Resolve(r)
+	for _, arg := range c.arguments {
+		arg.Resolve(r)
+	}
+}
+
+func (g *Get) Resolve(r *Resolver) {
+	g.object.Resolve(r)
+	g.name.Str = r.intern(g.name.Str)
+}
+
+func (s *Set) Resolve(r *Resolver) {
+	s.value.Resolve(r)
+	s.object.Resolve(r)
+	s.name.Str = r.intern(s.name.Str)
+}
+
+func (g *Grouping) Resolve(r *Resolver) {
+	g.expr.Resolve(r)
+}
+
+func (l *Literal) Resolve(r *Resolver) {
+	if content, ok := l.token.Content.(string); ok {
+		l.token.Content = r.intern(content)
+	}
+}
+
+func (l *Logical) Resolve(r *Resolver) {
+	l.left.Resolve(r)
+	l.right.Resolve(r)
+}
+
+func (u *Unary) Resolve(r *Resolver) {
+	u.Expr.Resolve(r)
+}
+
+func (t *This) Resolve(r *Resolver) {
+	if r.currentClass == CT_NONE {
+		r.error(t.keyword, "Can't use 'this' outside of a class.")
+		return
+	}
+	r.resolveVariable("this", &t.binding)
+}
+
+func (s *Super) Resolve(r *Resolver) {
+	if r.currentClass == CT_NONE {
+		r.error(s.keyword, "Can't use 'super' outside of a class.")
+		return
+	}
+	if r.currentClass != CT_SUBCLASS {
+		r.error(s.keyword, "Can't use 'super' in a class with no superclass.")
+		return
+	}
+	r.resolveVariable("super", &s.binding)
+	r.resolveVariable("this", &s.this)
+	s.method.Str = r.intern(s.method.Str)
+}
+
+func (l *List) Resolve(r *Resolver) {
+	for _, element := range l.elements {
+		element.Resolve(r)
+	}
+}
+
+func (i *Index) Resolve(r *Resolver) {
+	i.object.Resolve(r)
+	i.index.Resolve(r)
+}
+
+func (s *SetIndex) Resolve(r *Resolver) {
+	s.object.Resolve(r)
+	s.index.Resolve(r)
+	s.value.Resolve(r)
+}
+
+func (i *Interpolation) Resolve(r *Resolver) {
+	for _, part := range i.parts {
+		part.Resolve(r)
+	}
+}
+
+func (m *Map) Resolve(r *Resolver) {
+	for i, key := range m.keys {
+		key.Resolve(r)
+		m.values[i].Resolve(r)
+	}
+}
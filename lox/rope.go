@@ -0,0 +1,71 @@
+package lox
+
+import "strings"
+
+// ropeThreshold is the length from which concatenating strings makes a rope
+// rather than copying both of them.
+const ropeThreshold = 64
+
+// rope is a long string made by concatenation. It keeps the two strings it
+// joins, each a Go string or another rope, and only puts the text together
+// when something needs it, so building a string one piece at a time takes
+// linear time rather than quadratic. Ropes are held in string Values and
+// never leave the evaluator: print, == and natives see ordinary strings.
+type rope struct {
+	left, right any
+	length      int
+	// flat holds the text once it has been put together, and then left and
+	// right are dropped.
+	flat string
+}
+
+// concat joins two string values.
+func concat(left, right Value) Value {
+	length := left.stringLength() + right.stringLength()
+	if length < ropeThreshold {
+		return stringValue(left.str() + right.str())
+	}
+	return Value{kind: VK_STRING, ref: &rope{left: left.ref, right: right.ref, length: length}}
+}
+
+func (r *rope) String() string {
+	if r.left == nil {
+		return r.flat
+	}
+	var sb strings.Builder
+	sb.Grow(r.length)
+	// Ropes built in a loop lean to the left, so they are walked with a
+	// stack of pending right halves rather than by recursion.
+	pending := []any{r}
+	for len(pending) > 0 {
+		part := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		switch part := part.(type) {
+		case string:
+			sb.WriteString(part)
+		case *rope:
+			if part.left == nil {
+				sb.WriteString(part.flat)
+			} else {
+				pending = append(pending, part.right, part.left)
+			}
+		}
+	}
+	r.flat, r.left, r.right = sb.String(), nil, nil
+	return r.flat
+}
+
+// str returns the text of a string value.
+func (v Value) str() string {
+	if r, ok := v.ref.(*rope); ok {
+		return r.String()
+	}
+	return v.ref.(string)
+}
+
+func (v Value) stringLength() int {
+	if r, ok := v.ref.(*rope); ok {
+		return r.length
+	}
+	return len(v.ref.(string))
+}
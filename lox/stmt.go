@@ -0,0 +1,86 @@
+package lox
+
+type Stmt interface {
+	node
+	Resolve(r *Resolver)
+	Compile(c *Compiler)
+	// Optimize returns a simpler statement that runs the same way, or nil
+	// if the statement does nothing.
+	Optimize() Stmt
+}
+
+type PrintStatement struct {
+	Value Expr
+}
+
+type ExpressionStatement struct {
+	Expr Expr
+}
+
+type VarStatement struct {
+	Name        *Token
+	Initializer Expr
+	binding     Binding
+}
+
+type Block struct {
+	Statements []Stmt
+}
+
+type IfStatement struct {
+	Condition  Expr
+	ThenBranch Stmt
+	ElseBranch Stmt
+}
+
+type WhileStatement struct {
+	Condition Expr
+	Body      Stmt
+	// Increment is the increment of a for loop, or nil. It runs after the
+	// body, including when the body continues.
+	Increment Expr
+	// Label is the name break and continue can give the loop, or nil.
+	Label *Token
+}
+
+// BreakStatement leaves a loop, and ContinueStatement goes on with its next
+// iteration. The loop is the innermost one around the statement, or the one
+// with the label.
+type BreakStatement struct {
+	keyword *Token
+	label   *Token
+	loop    *WhileStatement
+}
+
+type ContinueStatement struct {
+	keyword *Token
+	label   *Token
+	loop    *WhileStatement
+}
+
+type FunctionDeclaration struct {
+	Name    *Token
+	Params  []*Token
+	Body    []Stmt
+	binding Binding
+	// this and params bind the instance of a method and the parameters to
+	// their slots in the frame of a call, which has size slots in all.
+	this   Binding
+	params []Binding
+	size   int
+	// upvalues lists the variables captured from enclosing functions.
+	upvalues []upvalue
+}
+
+type ReturnStatement struct {
+	keyword *Token
+	value   Expr
+}
+
+type ClassDeclaration struct {
+	Name       *Token
+	Superclass *Variable
+	Methods    []*FunctionDeclaration
+	binding    Binding
+	super      Binding
+}
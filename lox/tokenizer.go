@@ -0,0 +1,523 @@
+package lox
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type TokenType uint8
+
+const (
+	UNKNOWN TokenType = iota
+	EOF
+	LEFT_PAREN
+	RIGHT_PAREN
+	LEFT_BRACE
+	RIGHT_BRACE
+	LEFT_BRACKET
+	RIGHT_BRACKET
+	COMMA
+	DOT
+	MINUS
+	PLUS
+	SEMICOLON
+	COLON
+	STAR
+	EQUAL
+	EQUAL_EQUAL
+	BANG
+	BANG_EQUAL
+	LESS
+	LESS_EQUAL
+	GREATER
+	GREATER_EQUAL
+	SLASH
+	STRING
+	INTERPOLATION
+	NUMBER
+	IDENTIFIER
+	COMMENT
+	AND
+	BREAK
+	CLASS
+	CONTINUE
+	ELSE
+	FALSE
+	FOR
+	FUN
+	IF
+	NIL
+	OR
+	PRINT
+	RETURN
+	SUPER
+	THIS
+	TRUE
+	VAR
+	WHILE
+)
+
+func (tt TokenType) String() string {
+	switch tt {
+	case EOF:
+		return "EOF"
+	case LEFT_PAREN:
+		return "LEFT_PAREN"
+	case RIGHT_PAREN:
+		return "RIGHT_PAREN"
+	case LEFT_BRACE:
+		return "LEFT_BRACE"
+	case RIGHT_BRACE:
+		return "RIGHT_BRACE"
+	case LEFT_BRACKET:
+		return "LEFT_BRACKET"
+	case RIGHT_BRACKET:
+		return "RIGHT_BRACKET"
+	case COMMA:
+		return "COMMA"
+	case DOT:
+		return "DOT"
+	case MINUS:
+		return "MINUS"
+	case PLUS:
+		return "PLUS"
+	case SEMICOLON:
+		return "SEMICOLON"
+	case COLON:
+		return "COLON"
+	case STAR:
+		return "STAR"
+	case EQUAL:
+		return "EQUAL"
+	case EQUAL_EQUAL:
+		return "EQUAL_EQUAL"
+	case BANG:
+		return "BANG"
+	case BANG_EQUAL:
+		return "BANG_EQUAL"
+	case LESS:
+		return "LESS"
+	case LESS_EQUAL:
+		return "LESS_EQUAL"
+	case GREATER:
+		return "GREATER"
+	case GREATER_EQUAL:
+		return "GREATER_EQUAL"
+	case SLASH:
+		return "SLASH"
+	case STRING:
+		return "STRING"
+	case INTERPOLATION:
+		return "INTERPOLATION"
+	case NUMBER:
+		return "NUMBER"
+	case IDENTIFIER:
+		return "IDENTIFIER"
+	case COMMENT:
+		return "COMMENT"
+	case AND:
+		return "AND"
+	case BREAK:
+		return "BREAK"
+	case CLASS:
+		return "CLASS"
+	case CONTINUE:
+		return "CONTINUE"
+	case ELSE:
+		return "ELSE"
+	case FALSE:
+		return "FALSE"
+	case FOR:
+		return "FOR"
+	case FUN:
+		return "FUN"
+	case IF:
+		return "IF"
+	case NIL:
+		return "NIL"
+	case OR:
+		return "OR"
+	case PRINT:
+		return "PRINT"
+	case RETURN:
+		return "RETURN"
+	case SUPER:
+		return "SUPER"
+	case THIS:
+		return "THIS"
+	case TRUE:
+		return "TRUE"
+	case VAR:
+		return "VAR"
+	case WHILE:
+		return "WHILE"
+	}
+	return "UNKNOWN"
+}
+
+var reservedKeywords = map[string]TokenType{
+	"and":      AND,
+	"break":    BREAK,
+	"class":    CLASS,
+	"continue": CONTINUE,
+	"else":     ELSE,
+	"false":    FALSE,
+	"for":      FOR,
+	"fun":      FUN,
+	"if":       IF,
+	"nil":      NIL,
+	"or":       OR,
+	"print":    PRINT,
+	"return":   RETURN,
+	"super":    SUPER,
+	"this":     THIS,
+	"true":     TRUE,
+	"var":      VAR,
+	"while":    WHILE,
+}
+
+type Token struct {
+	Type    TokenType
+	Str     string
+	Content any
+	Line    int
+	// Column is the 1-based position of the first character of the token in
+	// its line, counted in characters rather than bytes.
+	Column int
+	// Offset and Length locate the token text in the source, in bytes.
+	Offset int
+	Length int
+}
+
+// End returns the offset of the first source byte after the token.
+func (t *Token) End() int {
+	return t.Offset + t.Length
+}
+
+func (t Token) String() string {
+	switch t.Type {
+	case STRING, INTERPOLATION:
+		return fmt.Sprintf("%v %s %s", t.Type, t.Str, t.Content)
+	case NUMBER:
+		value, _ := t.Content.(float64)
+		return fmt.Sprintf("%v %s %s", t.Type, t.Str, FloatFormat(value))
+	case COMMENT, UNKNOWN:
+		return ""
+	default:
+		return fmt.Sprintf("%v %s null", t.Type, t.Str)
+	}
+}
+
+func FloatFormat(value float64) string {
+	if value == float64(int(value)) {
+		return fmt.Sprintf("%.1f", value)
+	} else {
+		return fmt.Sprintf("%g", value)
+	}
+}
+
+// Tokenize scans the source into a slice of tokens terminated by an EOF token.
+// Scanning continues past lexical errors, which are returned joined together
+// along with every token that could be recognized.
+func Tokenize(fileContents []byte) ([]Token, error) {
+	return tokenize(fileContents, 0, 1)
+}
+
+// tokenize scans the source from the start offset, which must be at the
+// beginning of the given line. Token positions are relative to the whole
+// source.
+func tokenize(fileContents []byte, start int, line int) ([]Token, error) {
+	lineStart := start
+	// column counts on from the last offset it was asked about, so that
+	// columns cost time in proportion to the text between tokens rather than
+	// to the length of the line. Offsets asked about never go back within a
+	// line.
+	counted, counter := lineStart, 1
+	column := func(offset int) int {
+		if counted < lineStart {
+			counted, counter = lineStart, 1
+		}
+		counter += utf8.RuneCount(fileContents[counted:offset])
+		counted = offset
+		return counter
+	}
+	result := []Token{}
+	var tt TokenType
+	var tokenStr []byte
+	var lexicalErrors []error
+	unterminated := func(token *Token) {
+		errorToken := &Token{Type: UNKNOWN, Str: string(fileContents[token.Offset:]), Line: token.Line, Column: token.Column, Offset: token.Offset, Length: len(fileContents) - token.Offset}
+		lexicalErrors = append(lexicalErrors, &SyntaxError{errorToken, "Unterminated string."})
+	}
+	// interpolations holds a level for every string with an embedded
+	// expression being scanned, innermost last. depth counts the braces open
+	// in the expression, so that the '}' ending it can be told apart.
+	type interpolation struct {
+		depth int
+		// first is the index in result of the first token of the string.
+		first int
+	}
+	var interpolations []interpolation
+	// scanString scans a string from its opening quote, or from the '}'
+	// ending an embedded expression, to its closing quote or to the next
+	// "${". It returns the offset of the last character scanned. Strings in
+	// backquotes are raw: backslashes and "${" in them are kept as they are.
+	// Both kinds can span lines, and a carriage return before a newline is
+	// dropped.
+	scanString := func(i int, quote byte, first int) (TokenType, any, int) {
+		raw := quote == '`'
+		opening := Token{Line: line, Column: column(i), Offset: i}
+		sb := strings.Builder{}
+		j := i + 1
+		for ; j < len(fileContents) && fileContents[j] != quote; j++ {
+			switch c := fileContents[j]; {
+			case c == '\n':
+				line++
+				lineStart = j + 1
+				sb.WriteByte(c)
+			case c == '\r' && j+1 < len(fileContents) && fileContents[j+1] == '\n':
+			case c == '\\' && !raw && j+1 < len(fileContents):
+				r, length, msg := unescape(fileContents[j:])
+				if msg != "" {
+					errorToken := &Token{Type: UNKNOWN, Str: string(fileContents[j : j+length]), Line: line, Column: column(j), Offset: j, Length: length}
+					lexicalErrors = append(lexicalErrors, &SyntaxError{errorToken, msg})
+					continue
+				}
+				sb.WriteRune(r)
+				j += length - 1
+			case c == '$' && !raw && j+1 < len(fileContents) && fileContents[j+1] == '{':
+				interpolations = append(interpolations, interpolation{first: first})
+				return INTERPOLATION, sb.String(), j + 1
+			default:
+				sb.WriteByte(c)
+			}
+		}
+		if j < len(fileContents) {
+			return STRING, sb.String(), j
+		}
+		// A string left open inside an embedded expression leaves the strings
+		// around it open too. Only the outermost one is reported.
+		if len(interpolations) > 0 {
+			first, interpolations = interpolations[0].first, nil
+		}
+		if first < len(result) {
+			opening = result[first]
+		}
+		unterminated(&opening)
+		return UNKNOWN, nil, j
+	}
+	for i := start; i < len(fileContents); i++ {
+		ch := fileContents[i]
+		var content any = "null"
+		if ch == ' ' || ch == '\t' || ch == '\r' {
+			continue
+		}
+		if ch == '\n' {
+			line++
+			lineStart = i + 1
+			continue
+		}
+		// Strings can span lines, so the position of a token is taken before
+		// scanning it.
+		tokenLine, tokenColumn := line, column(i)
+		tokenStr = fileContents[i : i+1]
+		switch ch {
+		case '(':
+			tt = LEFT_PAREN
+		case ')':
+			tt = RIGHT_PAREN
+		case '{':
+			tt = LEFT_BRACE
+			if len(interpolations) > 0 {
+				interpolations[len(interpolations)-1].depth++
+			}
+		case '}':
+			tt = RIGHT_BRACE
+			if n := len(interpolations); n > 0 && interpolations[n-1].depth > 0 {
+				interpolations[n-1].depth--
+			} else if n > 0 {
+				first := interpolations[n-1].first
+				interpolations = interpolations[:n-1]
+				from := i
+				tt, content, i = scanString(i, '"', first)
+				tokenStr = fileContents[from:min(i+1, len(fileContents))]
+			}
+		case '[':
+			tt = LEFT_BRACKET
+		case ']':
+			tt = RIGHT_BRACKET
+		case ',':
+			tt = COMMA
+		case '.':
+			tt = DOT
+		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			tt = NUMBER
+			j := i
+			hasDot := false
+			for j < len(fileContents) && (fileContents[j] == '.' || isDigit(fileContents[j])) {
+				if fileContents[j] == '.' {
+					if j+1 >= len(fileContents) || !isDigit(fileContents[j+1]) {
+						break
+					}
+					if hasDot {
+						break
+					} else {
+						hasDot = true
+					}
+				}
+				j++
+			}
+			if j <= len(fileContents) {
+				j--
+			}
+			tokenStr = fileContents[i : j+1]
+			i = j
+			content, _ = strconv.ParseFloat(string(tokenStr), 64)
+		case '-':
+			tt = MINUS
+		case '+':
+			tt = PLUS
+		case ';':
+			tt = SEMICOLON
+		case ':':
+			tt = COLON
+		case '*':
+			tt = STAR
+		case '=':
+			tt = EQUAL
+			if i+1 < len(fileContents) && fileContents[i+1] == '=' {
+				tt = EQUAL_EQUAL
+				tokenStr = fileContents[i : i+2]
+				i++
+			}
+		case '!':
+			tt = BANG
+			if i+1 < len(fileContents) && fileContents[i+1] == '=' {
+				tt = BANG_EQUAL
+				tokenStr = fileContents[i : i+2]
+				i++
+			}
+		case '<':
+			tt = LESS
+			if i+1 < len(fileContents) && fileContents[i+1] == '=' {
+				tt = LESS_EQUAL
+				tokenStr = fileContents[i : i+2]
+				i++
+			}
+		case '>':
+			tt = GREATER
+			if i+1 < len(fileContents) && fileContents[i+1] == '=' {
+				tt = GREATER_EQUAL
+				tokenStr = fileContents[i : i+2]
+				i++
+			}
+		case '/':
+			tt = SLASH
+			if i+1 < len(fileContents) && fileContents[i+1] == '/' {
+				tt = COMMENT
+				for i < len(fileContents) && fileContents[i] != '\n' {
+					i++
+				}
+				if i < len(fileContents) && fileContents[i] == '\n' {
+					line++
+					lineStart = i + 1
+				}
+			}
+		case '"', '`':
+			from := i
+			tt, content, i = scanString(i, ch, len(result))
+			tokenStr = fileContents[from:min(i+1, len(fileContents))]
+		default:
+			r, size := utf8.DecodeRune(fileContents[i:])
+			if r == '_' || isLetter(r) {
+				tt = IDENTIFIER
+				j := i + size
+				for j < len(fileContents) {
+					r, size := utf8.DecodeRune(fileContents[j:])
+					if !IsIdentifierPart(r) {
+						break
+					}
+					j += size
+				}
+				tokenStr = fileContents[i:j]
+				i = j - 1
+				if kwType, found := reservedKeywords[string(tokenStr)]; found {
+					tt = kwType
+				}
+			} else {
+				tt = UNKNOWN
+				errorToken := &Token{Type: UNKNOWN, Str: string(fileContents[i : i+size]), Line: line, Column: tokenColumn, Offset: i, Length: size}
+				lexicalErrors = append(lexicalErrors, &SyntaxError{errorToken, fmt.Sprintf("Unexpected character: %c", r)})
+				i += size - 1
+			}
+		}
+		if tt == UNKNOWN || tt == COMMENT {
+			continue
+		}
+		offset := i + 1 - len(tokenStr)
+		token := Token{
+			tt,
+			string(tokenStr),
+			content,
+			tokenLine,
+			tokenColumn,
+			offset,
+			len(tokenStr),
+		}
+		result = append(result, token)
+	}
+	if len(interpolations) > 0 {
+		unterminated(&result[interpolations[0].first])
+	}
+	eofToken := Token{
+		EOF,
+		"",
+		"",
+		line,
+		column(len(fileContents)),
+		len(fileContents),
+		0,
+	}
+	result = append(result, eofToken)
+	return result, errors.Join(lexicalErrors...)
+}
+
+// unescape decodes the escape sequence at the start of text, which begins
+// with a backslash and another character, and returns the character it
+// stands for and its length in bytes. For an invalid sequence it returns a
+// message, with the length of the text to report it at.
+func unescape(text []byte) (ch rune, length int, msg string) {
+	switch text[1] {
+	case 'n':
+		return '\n', 2, ""
+	case 't':
+		return '\t', 2, ""
+	case 'r':
+		return '\r', 2, ""
+	case '"':
+		return '"', 2, ""
+	case '\\':
+		return '\\', 2, ""
+	case '$':
+		return '$', 2, ""
+	case 'u':
+		// Up to six hex digits in braces, as in \u{1F600}.
+		if braced := text[2:]; len(braced) > 0 && braced[0] == '{' {
+			if end := bytes.IndexByte(braced[:min(len(braced), 8)], '}'); end > 1 {
+				length := 2 + end + 1
+				code, err := strconv.ParseUint(string(braced[1:end]), 16, 32)
+				if err != nil || !utf8.ValidRune(rune(code)) {
+					return 0, length, fmt.Sprintf("Invalid Unicode escape sequence '%s'.", text[:length])
+				}
+				return rune(code), length, ""
+			}
+		}
+		return 0, 2, "Expect hex digits in braces after '\\u'."
+	}
+	_, size := utf8.DecodeRune(text[1:])
+	return 0, 1 + size, fmt.Sprintf("Invalid escape sequence '%s'.", text[:1+size])
+}
@@ -0,0 +1,140 @@
+package lox
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNumbers(t *testing.T) {
+	tokens, err := Tokenize([]byte("1234.1234\n.123\n456.\n123"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		t.Log(token)
+	}
+}
+
+func TestIdentifiers(t *testing.T) {
+	tokens, err := Tokenize([]byte("_123bar f00 6az bar 6ar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		t.Log(token)
+	}
+}
+
+func TestLexicalErrors(t *testing.T) {
+	tokens, err := Tokenize([]byte("var a = $;\n\"open"))
+	var syntaxError *SyntaxError
+	if !errors.As(err, &syntaxError) {
+		t.Fatalf("expected a syntax error, got %v", err)
+	}
+	if want := "[line 1] Error: Unexpected character: $\n[line 2] Error: Unterminated string."; err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+	if last := tokens[len(tokens)-1]; last.Type != EOF {
+		t.Errorf("expected tokens to end with EOF, got %v", last)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	tokens, err := Tokenize([]byte("var s = \"é\";\n  print s;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ line, column, offset, length int }{
+		{1, 1, 0, 3},   // var
+		{1, 5, 4, 1},   // s
+		{1, 7, 6, 1},   // =
+		{1, 9, 8, 4},   // "é"
+		{1, 12, 12, 1}, // ;
+		{2, 3, 16, 5},  // print
+		{2, 9, 22, 1},  // s
+		{2, 10, 23, 1}, // ;
+		{2, 11, 24, 0}, // EOF
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %d tokens, want %d", len(tokens), len(want))
+	}
+	for i, w := range want {
+		tok := tokens[i]
+		if tok.Line != w.line || tok.Column != w.column || tok.Offset != w.offset || tok.Length != w.length {
+			t.Errorf("token %d %q at %d:%d offset %d length %d, want %d:%d offset %d length %d",
+				i, tok.Str, tok.Line, tok.Column, tok.Offset, tok.Length, w.line, w.column, w.offset, w.length)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	source := "\"tab\\there \\\"quoted\\\" \\\\ \\u{1F600}\" `raw \\n`\r\n\"two\r\nlines\" end"
+	tokens, err := Tokenize([]byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		content      any
+		line, column int
+	}{
+		{"tab\there \"quoted\" \\ 😀", 1, 1},
+		{"raw \\n", 1, 37},
+		{"two\nlines", 2, 1},
+		{"null", 3, 8},
+	}
+	for i, w := range want {
+		tok := tokens[i]
+		if tok.Content != w.content || tok.Line != w.line || tok.Column != w.column {
+			t.Errorf("token %d has %q at %d:%d, want %q at %d:%d", i, tok.Content, tok.Line, tok.Column, w.content, w.line, w.column)
+		}
+	}
+}
+
+func TestInvalidEscapes(t *testing.T) {
+	_, err := Tokenize([]byte(`"\q \u{110000} \u41"`))
+	want := "[line 1] Error: Invalid escape sequence '\\q'.\n" +
+		"[line 1] Error: Invalid Unicode escape sequence '\\u{110000}'.\n" +
+		"[line 1] Error: Expect hex digits in braces after '\\u'."
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	tokens, err := Tokenize([]byte("café 名前 नमस्ते _x1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"café", "名前", "नमस्ते", "_x1"} {
+		if tokens[i].Type != IDENTIFIER || tokens[i].Str != name {
+			t.Errorf("token %d is %v %q, want identifier %q", i, tokens[i].Type, tokens[i].Str, name)
+		}
+	}
+}
+
+func TestInterpolationTokens(t *testing.T) {
+	tokens, err := Tokenize([]byte(`"a ${ {"k": "${x}"}["k"] } b"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, token := range tokens {
+		types = append(types, token.Type.String())
+	}
+	want := "INTERPOLATION LEFT_BRACE STRING COLON INTERPOLATION IDENTIFIER STRING RIGHT_BRACE LEFT_BRACKET STRING RIGHT_BRACKET STRING EOF"
+	if got := strings.Join(types, " "); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	for source, want := range map[string]string{
+		`"open ${x`: "[line 1] Error: Unterminated string.",
+		// The quote inside the embed starts a string of its own, which only
+		// the string around it is reported for.
+		`print "open ${1 + 2";`:         "[line 1] Error: Unterminated string.",
+		"var x = 1;\nprint \"a ${x} b;": "[line 2] Error: Unterminated string.",
+	} {
+		if _, err := Tokenize([]byte(source)); err == nil || err.Error() != want {
+			t.Errorf("%q gave %v, want %q", source, err, want)
+		}
+	}
+}
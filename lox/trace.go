@@ -0,0 +1,126 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CallFrame records a call to a function, method or class that has not
+// returned yet.
+type CallFrame struct {
+	// Function is the name of the function or method called.
+	Function string
+	// Class is the class a method belongs to, or empty for plain functions.
+	Class string
+	// Line is the line of the call site, or 0 for calls made from Go.
+	Line int
+	// Native is set for functions implemented in Go.
+	Native bool
+	// Elided counts the calls this one replaced: a function that returns
+	// the result of a call makes it in its own frame.
+	Elided int
+}
+
+func (f CallFrame) String() string {
+	if f.Class != "" {
+		return fmt.Sprintf("%s.%s()", f.Class, f.Function)
+	}
+	return f.Function + "()"
+}
+
+// CallStack returns the calls in progress, outermost first. Native functions
+// can use it to find out where they were called from.
+func (in *Interpreter) CallStack() []CallFrame {
+	return append([]CallFrame(nil), in.frames...)
+}
+
+// pushFrame records a call made from the given call site, or from Go when
+// paren is nil. It fails once MaxDepth calls are in progress.
+func (in *Interpreter) pushFrame(callee LoxCallable, paren *Token) {
+	if len(in.frames) >= in.MaxDepth {
+		runtimeError(paren, "Stack overflow.")
+	}
+	in.frames = append(in.frames, newCallFrame(callee, paren))
+}
+
+func newCallFrame(callee LoxCallable, paren *Token) CallFrame {
+	frame := CallFrame{}
+	if paren != nil {
+		frame.Line = paren.Line
+	}
+	switch callee := callee.(type) {
+	case *LoxFunction:
+		frame.Function = callee.declaration.Name.Str
+		if callee.class != nil {
+			frame.Class = callee.class.name
+		}
+	case *Closure:
+		frame.Function = callee.function.Name
+		if callee.class != nil {
+			frame.Class = callee.class.name
+		}
+	case *BoundMethod:
+		frame.Function, frame.Class = callee.method.function.Name, callee.method.class.name
+	case *LoxClass:
+		frame.Function = callee.name
+		if initializer := callee.FindMethod("init"); initializer != nil {
+			frame.Function, frame.Class = "init", initializer.declaringClass().name
+		}
+	case *NativeFunction:
+		frame.Function, frame.Native = callee.Name, true
+	case *HostClass:
+		frame.Function, frame.Native = callee.name, true
+	default:
+		frame.Function = callee.String()
+	}
+	return frame
+}
+
+func (in *Interpreter) popFrame() {
+	in.frames = in.frames[:len(in.frames)-1]
+}
+
+// replaceFrame records a call the function on top of the call stack made in
+// tail position, which runs in its place. It takes no room of its own, so
+// unlike pushFrame it can't overflow the stack.
+func (in *Interpreter) replaceFrame(callee LoxCallable, paren *Token) {
+	frame := newCallFrame(callee, paren)
+	caller := &in.frames[len(in.frames)-1]
+	caller.Function, caller.Class = frame.Function, frame.Class
+	caller.Elided++
+}
+
+// traceEnds is how many of the innermost and of the outermost calls are
+// listed in traces that are too deep to list in full.
+const traceEnds = 10
+
+// formatTrace lists the calls that led to a runtime error at the given line,
+// innermost first, the way clox does. The script itself is left out when the
+// outermost call was made from Go.
+func formatTrace(sb *strings.Builder, line int, trace []CallFrame) {
+	for i := len(trace) - 1; i >= 0; i-- {
+		if skipped := len(trace) - 2*traceEnds; skipped > 0 && i < len(trace)-traceEnds && i >= traceEnds {
+			if i == traceEnds {
+				fmt.Fprintf(sb, "[%d more calls]\n", skipped)
+			}
+			line = trace[i].Line
+			continue
+		}
+		if trace[i].Native {
+			fmt.Fprintf(sb, "[native] in %s\n", trace[i])
+		} else {
+			fmt.Fprintf(sb, "[line %d] in %s\n", line, trace[i])
+		}
+		switch trace[i].Elided {
+		case 0:
+		case 1:
+			sb.WriteString("[1 tail call elided]\n")
+		default:
+			fmt.Fprintf(sb, "[%d tail calls elided]\n", trace[i].Elided)
+		}
+		line = trace[i].Line
+	}
+	if line != 0 {
+		fmt.Fprintf(sb, "[line %d] in script\n", line)
+	}
+}
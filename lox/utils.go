@@ -0,0 +1,18 @@
+package lox
+
+import "unicode"
+
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch)
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// IsIdentifierPart reports whether ch can follow the first character of an
+// identifier. Combining marks are allowed so that words in scripts that
+// use them can be written in full.
+func IsIdentifierPart(ch rune) bool {
+	return ch == '_' || isLetter(ch) || unicode.IsDigit(ch) || unicode.In(ch, unicode.Mn, unicode.Mc)
+}
@@ -0,0 +1,222 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ValueKind tells what a Value holds.
+type ValueKind uint8
+
+const (
+	VK_NIL ValueKind = iota
+	VK_BOOL
+	VK_NUMBER
+	VK_STRING
+	VK_OBJECT
+)
+
+// Value is a Lox value as the evaluator and the VM pass it around. Numbers,
+// booleans and nil are held inline, so computing with them doesn't allocate.
+// Strings, callables, instances and every other value are held in ref; a
+// string is a Go string or, once concatenation makes it long, a rope. The
+// zero Value is nil.
+//
+// Native functions and the embedding API see values as an any holding nil,
+// a bool, a float64, a string or the object itself; ValueOf and Any convert
+// between the two.
+type Value struct {
+	kind ValueKind
+	// number holds numbers, and booleans as 0 or 1.
+	number float64
+	ref    any
+}
+
+var (
+	nilValue   = Value{}
+	trueValue  = Value{kind: VK_BOOL, number: 1}
+	falseValue = Value{kind: VK_BOOL}
+)
+
+func numberValue(n float64) Value {
+	return Value{kind: VK_NUMBER, number: n}
+}
+
+func boolValue(b bool) Value {
+	if b {
+		return trueValue
+	}
+	return falseValue
+}
+
+func stringValue(s string) Value {
+	return Value{kind: VK_STRING, ref: s}
+}
+
+// objectValue holds a value that is neither nil, a boolean, a number nor a
+// string.
+func objectValue(object any) Value {
+	return Value{kind: VK_OBJECT, ref: object}
+}
+
+// ValueOf converts a Lox value held in an any to a Value.
+func ValueOf(value any) Value {
+	switch v := value.(type) {
+	case nil:
+		return nilValue
+	case bool:
+		return boolValue(v)
+	case float64:
+		return numberValue(v)
+	case string:
+		// Reuse the interface rather than boxing the string again.
+		return Value{kind: VK_STRING, ref: value}
+	}
+	return objectValue(value)
+}
+
+// Any returns the value held in an any, the way native functions see it.
+func (v Value) Any() any {
+	switch v.kind {
+	case VK_NIL:
+		return nil
+	case VK_BOOL:
+		return v.number != 0
+	case VK_NUMBER:
+		return v.number
+	case VK_STRING:
+		if r, ok := v.ref.(*rope); ok {
+			return r.String()
+		}
+	}
+	return v.ref
+}
+
+func (v Value) isTruthy() bool {
+	switch v.kind {
+	case VK_NIL:
+		return false
+	case VK_BOOL:
+		return v.number != 0
+	}
+	return true
+}
+
+// equals compares values the way == does in Lox.
+func (v Value) equals(other Value) bool {
+	if v.kind != other.kind {
+		return false
+	}
+	switch v.kind {
+	case VK_NIL:
+		return true
+	case VK_BOOL, VK_NUMBER:
+		return v.number == other.number
+	case VK_STRING:
+		return v.stringLength() == other.stringLength() && v.str() == other.str()
+	}
+	return v.ref == other.ref
+}
+
+// String formats the value the way print shows it.
+func (v Value) String() string {
+	switch v.kind {
+	case VK_NIL:
+		return "nil"
+	case VK_BOOL:
+		return strconv.FormatBool(v.number != 0)
+	case VK_NUMBER:
+		if v.number == float64(int(v.number)) {
+			return fmt.Sprintf("%.0f", v.number)
+		}
+		return fmt.Sprintf("%g", v.number)
+	case VK_STRING:
+		return v.str()
+	}
+	return fmt.Sprint(v.ref)
+}
+
+// Stringify formats a value the way print shows it.
+func Stringify(value any) string {
+	return ValueOf(value).String()
+}
+
+// formatValue writes a value the way print shows it, as an element of the
+// lists and maps in enclosing. A list or map inside itself is shown as [...]
+// or {...}.
+func formatValue(sb *strings.Builder, value Value, enclosing []any) {
+	switch container := value.ref.(type) {
+	case *LoxList:
+		container.format(sb, enclosing)
+	case *LoxMap:
+		container.format(sb, enclosing)
+	default:
+		sb.WriteString(value.String())
+	}
+}
+
+// interpolate joins the parts of an interpolated string, each shown the way
+// print shows it.
+func interpolate(parts []Value) Value {
+	sb := strings.Builder{}
+	for _, part := range parts {
+		formatValue(&sb, part, nil)
+	}
+	return stringValue(sb.String())
+}
+
+// getIndex reads the element of a list at an index, or the value of a map
+// for a key. The error is reported as a runtime error at the index
+// expression.
+func getIndex(object, index Value) (Value, error) {
+	switch object := object.ref.(type) {
+	case *LoxList:
+		i, err := object.index(index, false)
+		if err != nil {
+			return nilValue, err
+		}
+		return object.elements[i], nil
+	case *LoxMap:
+		key, err := mapKey(index)
+		if err != nil {
+			return nilValue, err
+		}
+		value, found := object.get(key)
+		if !found {
+			return nilValue, fmt.Errorf("Undefined key '%s'.", index)
+		}
+		return value, nil
+	}
+	return nilValue, errors.New("Only lists and maps can be indexed.")
+}
+
+// setIndex replaces the element of a list at an index, or sets the value of
+// a map for a key.
+func setIndex(object, index, value Value) error {
+	switch object := object.ref.(type) {
+	case *LoxList:
+		i, err := object.index(index, false)
+		if err != nil {
+			return err
+		}
+		object.elements[i] = value
+		return nil
+	case *LoxMap:
+		key, err := mapKey(index)
+		if err != nil {
+			return err
+		}
+		object.set(key, value)
+		return nil
+	}
+	return errors.New("Only lists and maps can be indexed.")
+}
+
+// builtinObject is a value with built-in methods, such as a list.
+type builtinObject interface {
+	// method returns the method with the name bound to the value, or nil
+	// if there is none.
+	method(name string) *NativeFunction
+}
@@ -0,0 +1,91 @@
+package lox
+
+import (
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestValueOf(t *testing.T) {
+	class := &LoxClass{name: "Point"}
+	for _, value := range []any{nil, true, false, 1.5, "text", class} {
+		if got := ValueOf(value).Any(); got != value {
+			t.Errorf("ValueOf(%v).Any() = %v", value, got)
+		}
+	}
+
+	nan := ValueOf(math.NaN())
+	if nan.equals(nan) {
+		t.Error("NaN equals itself")
+	}
+	if ValueOf(0.0).equals(ValueOf(false)) || ValueOf(nil).equals(ValueOf(false)) {
+		t.Error("values of different types are equal")
+	}
+	if !ValueOf("a" + "b").equals(ValueOf("ab")) {
+		t.Error("equal strings are not equal")
+	}
+}
+
+func TestArithmeticDoesNotAllocate(t *testing.T) {
+	in := NewInterpreter()
+	in.Stdout = io.Discard
+	source := `
+		fun run() {
+			var sum = 0;
+			for (var i = 0; i < 1000; i = i + 1) {
+				sum = sum + i * 2 - i / 2;
+			}
+			return sum;
+		}
+	`
+	if err := in.Run([]byte(source)); err != nil {
+		t.Fatal(err)
+	}
+	run, _ := in.Global("run")
+	allocs := testing.AllocsPerRun(10, func() {
+		if _, err := in.Call(run); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// The call itself allocates a little, but the loop mustn't.
+	if allocs > 10 {
+		t.Errorf("a call running 1000 iterations made %v allocations", allocs)
+	}
+}
+
+func TestRopes(t *testing.T) {
+	in := NewInterpreter()
+	var got string
+	in.DefineNative(&NativeFunction{
+		Name:   "record",
+		Params: []ParamType{PT_STRING},
+		Fn: func(in *Interpreter, arguments []any) (any, error) {
+			got = arguments[0].(string)
+			return nil, nil
+		},
+	})
+	source := `
+		var s = "";
+		for (var i = 0; i < 100; i = i + 1) s = s + "ab";
+		var t = "";
+		for (var i = 0; i < 100; i = i + 1) t = "ab" + t;
+		if (s != t or s + "a" == t) print "wrong";
+		record(s);
+	`
+	if err := in.Run([]byte(source)); err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Repeat("ab", 100); got != want {
+		t.Errorf("the native saw %q", got)
+	}
+
+	long := stringValue(strings.Repeat("x", ropeThreshold))
+	value := concat(concat(long, stringValue("y")), long)
+	if _, ok := value.ref.(*rope); !ok {
+		t.Fatal("concatenating long strings didn't make a rope")
+	}
+	if value.Any() != value.String() || len(value.String()) != 2*ropeThreshold+1 {
+		t.Errorf("the rope holds %q", value.String())
+	}
+}
@@ -0,0 +1,598 @@
+package lox
+
+import "fmt"
+
+// VM runs compiled functions on a value stack. Lox calls don't recurse in
+// Go: each call pushes a frame and the same loop carries on running the
+// callee. The VM is re-entered when Go code, such as a native function,
+// calls back into a compiled function.
+type VM struct {
+	in           *Interpreter
+	stack        []Value
+	frames       []vmFrame
+	openUpvalues []*Upvalue
+}
+
+type vmFrame struct {
+	closure *Closure
+	ip      int
+	// base is the stack index of slot 0, which holds the callee or the
+	// instance a method was called on.
+	base int
+	// traced is set for calls made by the VM, which recorded them in the
+	// interpreter's call stack.
+	traced bool
+}
+
+// Closure is a compiled function together with the variables it captured.
+type Closure struct {
+	function *CompiledFunction
+	upvalues []*Upvalue
+	// class is the class of a method.
+	class *LoxClass
+}
+
+func (c *Closure) Arity() int {
+	return c.function.Arity
+}
+
+func (c *Closure) String() string {
+	return c.function.String()
+}
+
+func (c *Closure) Call(in *Interpreter, arguments []Value) Value {
+	return in.machine().call(c, objectValue(c), arguments)
+}
+
+func (c *Closure) Bind(instance *LoxInstance) LoxCallable {
+	return &BoundMethod{instance, c}
+}
+
+func (c *Closure) callMethod(in *Interpreter, receiver *LoxInstance, arguments []Value) Value {
+	return in.machine().call(c, objectValue(receiver), arguments)
+}
+
+func (c *Closure) declaringClass() *LoxClass {
+	return c.class
+}
+
+// BoundMethod is a compiled method bound to an instance.
+type BoundMethod struct {
+	receiver *LoxInstance
+	method   *Closure
+}
+
+func (m *BoundMethod) Arity() int {
+	return m.method.Arity()
+}
+
+func (m *BoundMethod) String() string {
+	return m.method.String()
+}
+
+func (m *BoundMethod) Call(in *Interpreter, arguments []Value) Value {
+	return m.method.callMethod(in, m.receiver, arguments)
+}
+
+// Upvalue is a variable captured by a closure. It refers to the variable's
+// stack slot until the variable goes out of scope, and then holds the value
+// itself.
+type Upvalue struct {
+	slot   int
+	closed bool
+	value  Value
+}
+
+func (vm *VM) getUpvalue(upvalue *Upvalue) Value {
+	if upvalue.closed {
+		return upvalue.value
+	}
+	return vm.stack[upvalue.slot]
+}
+
+func (vm *VM) setUpvalue(upvalue *Upvalue, value Value) {
+	if upvalue.closed {
+		upvalue.value = value
+	} else {
+		vm.stack[upvalue.slot] = value
+	}
+}
+
+// captureUpvalue returns the upvalue for a stack slot, sharing it with the
+// closures that already captured the slot.
+func (vm *VM) captureUpvalue(slot int) *Upvalue {
+	i := len(vm.openUpvalues)
+	for i > 0 && vm.openUpvalues[i-1].slot >= slot {
+		if vm.openUpvalues[i-1].slot == slot {
+			return vm.openUpvalues[i-1]
+		}
+		i--
+	}
+	upvalue := &Upvalue{slot: slot}
+	vm.openUpvalues = append(vm.openUpvalues, nil)
+	copy(vm.openUpvalues[i+1:], vm.openUpvalues[i:])
+	vm.openUpvalues[i] = upvalue
+	return upvalue
+}
+
+// closeUpvalues moves the variables in the stack slots from last upwards
+// into the upvalues that captured them.
+func (vm *VM) closeUpvalues(last int) {
+	i := len(vm.openUpvalues)
+	for i > 0 && vm.openUpvalues[i-1].slot >= last {
+		upvalue := vm.openUpvalues[i-1]
+		upvalue.value, upvalue.closed = vm.stack[upvalue.slot], true
+		i--
+	}
+	vm.openUpvalues = vm.openUpvalues[:i]
+}
+
+// machine returns the VM of the interpreter, creating it on first use.
+func (in *Interpreter) machine() *VM {
+	if in.vm == nil {
+		in.vm = &VM{in: in, stack: make([]Value, 0, 256)}
+	}
+	return in.vm
+}
+
+// ExecuteCompiled runs a script compiled by Compile.
+func (in *Interpreter) ExecuteCompiled(script *CompiledFunction) (err error) {
+	defer in.recoverRuntimeError(&err, in.save())
+	closure := &Closure{function: script}
+	in.machine().call(closure, objectValue(closure), nil)
+	return nil
+}
+
+// call runs a closure with the given value in slot 0 until it returns. If a
+// runtime error interrupts it, the stack is unwound to where it was.
+func (vm *VM) call(closure *Closure, receiver Value, arguments []Value) Value {
+	base, depth := len(vm.stack), len(vm.frames)
+	defer func() {
+		if r := recover(); r != nil {
+			vm.closeUpvalues(base)
+			vm.stack = vm.stack[:base]
+			vm.frames = vm.frames[:depth]
+			panic(r)
+		}
+	}()
+	vm.stack = append(vm.stack, receiver)
+	vm.stack = append(vm.stack, arguments...)
+	vm.frames = append(vm.frames, vmFrame{closure: closure, base: base})
+	return vm.run(depth)
+}
+
+func (vm *VM) push(value Value) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() Value {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+func (vm *VM) peek(distance int) Value {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+// run executes instructions until the frame at the given depth returns.
+func (vm *VM) run(depth int) Value {
+	in := vm.in
+	frame := &vm.frames[len(vm.frames)-1]
+	chunk := &frame.closure.function.Chunk
+	code := chunk.Code
+	ip := frame.ip
+
+	readLong := func() int {
+		ip += 4
+		return int(code[ip-4])<<24 | int(code[ip-3])<<16 | int(code[ip-2])<<8 | int(code[ip-1])
+	}
+	// wide is set by OP_WIDE for the first operand of the next instruction.
+	wide := false
+	readShort := func() int {
+		if wide {
+			wide = false
+			return readLong()
+		}
+		ip += 2
+		return int(code[ip-2])<<8 | int(code[ip-1])
+	}
+	for {
+		start := ip
+		op := OpCode(code[ip])
+		ip++
+		switch op {
+		case OP_CONSTANT:
+			vm.push(chunk.Constants[readShort()])
+		case OP_NIL:
+			vm.push(nilValue)
+		case OP_TRUE:
+			vm.push(trueValue)
+		case OP_FALSE:
+			vm.push(falseValue)
+		case OP_POP:
+			vm.pop()
+		case OP_GET_LOCAL:
+			vm.push(vm.stack[frame.base+readShort()])
+		case OP_SET_LOCAL:
+			vm.stack[frame.base+readShort()] = vm.peek(0)
+		case OP_GET_GLOBAL:
+			vm.push(in.globals.get(readShort(), chunk.positions[start].token))
+		case OP_DEFINE_GLOBAL:
+			in.globals.values[readShort()] = vm.pop()
+		case OP_SET_GLOBAL:
+			in.globals.assign(readShort(), chunk.positions[start].token, vm.peek(0))
+		case OP_GET_UPVALUE:
+			vm.push(vm.getUpvalue(frame.closure.upvalues[readShort()]))
+		case OP_SET_UPVALUE:
+			vm.setUpvalue(frame.closure.upvalues[readShort()], vm.peek(0))
+		case OP_GET_PROPERTY:
+			name := chunk.Constants[readShort()].ref.(string)
+			vm.push(vm.getProperty(vm.pop(), name, chunk.positions[start]))
+		case OP_GET_METHOD:
+			name := chunk.Constants[readShort()].ref.(string)
+			object := vm.peek(0)
+			if instance, ok := object.ref.(*LoxInstance); ok {
+				if _, found := instance.fields[name]; !found {
+					if method, ok := instance.class.FindMethod(name).(*Closure); ok {
+						vm.stack[len(vm.stack)-1] = objectValue(method)
+						vm.push(object)
+						break
+					}
+				}
+			}
+			vm.stack[len(vm.stack)-1] = vm.getProperty(object, name, chunk.positions[start])
+			vm.push(nilValue)
+		case OP_CHECK_FIELDS:
+			switch vm.peek(0).ref.(type) {
+			case *LoxInstance, HostObject:
+			default:
+				pos := chunk.positions[start]
+				runtimeErrorSpan(pos.token, pos.span, "Only instances have fields.")
+			}
+		case OP_SET_PROPERTY:
+			name := chunk.Constants[readShort()].ref.(string)
+			value := vm.pop()
+			switch object := vm.pop().ref.(type) {
+			case *LoxInstance:
+				object.fields[name] = value
+			case HostObject:
+				if err := object.Set(in, name, value.Any()); err != nil {
+					runtimeError(chunk.positions[start].token, err.Error())
+				}
+			}
+			vm.push(nilValue)
+		case OP_GET_SUPER:
+			name := chunk.Constants[readShort()].ref.(string)
+			superclass := vm.pop().ref.(*LoxClass)
+			method := superclass.FindMethod(name)
+			if method == nil {
+				runtimeError(chunk.positions[start].token, "Undefined property '"+name+"'.")
+			}
+			vm.push(objectValue(method.Bind(vm.pop().ref.(*LoxInstance))))
+		case OP_EQUAL:
+			right := vm.pop()
+			vm.stack[len(vm.stack)-1] = boolValue(vm.peek(0).equals(right))
+		case OP_GREATER, OP_GREATER_EQUAL, OP_LESS, OP_LESS_EQUAL, OP_SUBTRACT, OP_MULTIPLY, OP_DIVIDE:
+			left, right := vm.peek(1), vm.peek(0)
+			if left.kind != VK_NUMBER || right.kind != VK_NUMBER {
+				pos := chunk.positions[start]
+				runtimeErrorSpan(pos.token, pos.span, "Operands must be numbers.")
+			}
+			var result Value
+			switch op {
+			case OP_GREATER:
+				result = boolValue(left.number > right.number)
+			case OP_GREATER_EQUAL:
+				result = boolValue(left.number >= right.number)
+			case OP_LESS:
+				result = boolValue(left.number < right.number)
+			case OP_LESS_EQUAL:
+				result = boolValue(left.number <= right.number)
+			case OP_SUBTRACT:
+				result = numberValue(left.number - right.number)
+			case OP_MULTIPLY:
+				result = numberValue(left.number * right.number)
+			case OP_DIVIDE:
+				result = numberValue(left.number / right.number)
+			}
+			vm.pop()
+			vm.stack[len(vm.stack)-1] = result
+		case OP_ADD:
+			left, right := vm.peek(1), vm.peek(0)
+			var result Value
+			switch {
+			case left.kind == VK_NUMBER && right.kind == VK_NUMBER:
+				result = numberValue(left.number + right.number)
+			case left.kind == VK_STRING && right.kind == VK_STRING:
+				result = concat(left, right)
+			default:
+				pos := chunk.positions[start]
+				runtimeErrorSpan(pos.token, pos.span, "Operands must be two numbers or two strings.")
+			}
+			vm.pop()
+			vm.stack[len(vm.stack)-1] = result
+		case OP_NOT:
+			vm.stack[len(vm.stack)-1] = boolValue(!vm.peek(0).isTruthy())
+		case OP_NEGATE:
+			value := vm.peek(0)
+			if value.kind != VK_NUMBER {
+				pos := chunk.positions[start]
+				runtimeErrorSpan(pos.token, pos.span, "Operand must be a number.")
+			}
+			vm.stack[len(vm.stack)-1] = numberValue(-value.number)
+		case OP_PRINT:
+			fmt.Fprintln(in.Stdout, vm.pop())
+		case OP_JUMP:
+			offset := readLong()
+			ip += offset
+		case OP_JUMP_IF_FALSE:
+			offset := readLong()
+			if !vm.peek(0).isTruthy() {
+				ip += offset
+			}
+		case OP_LOOP:
+			offset := readLong()
+			ip -= offset
+		case OP_CALL, OP_TAIL_CALL:
+			argCount := readShort()
+			frame.ip = ip
+			calls := len(vm.frames)
+			// Reload the frame even when the callee was run right away, since
+			// it may have re-entered the VM and moved the frames.
+			vm.callValue(argCount, chunk.positions[start], op == OP_TAIL_CALL)
+			if op == OP_TAIL_CALL {
+				vm.replaceCaller(calls)
+			}
+			frame = &vm.frames[len(vm.frames)-1]
+			chunk = &frame.closure.function.Chunk
+			code, ip = chunk.Code, frame.ip
+		case OP_INVOKE, OP_TAIL_INVOKE:
+			argCount := readShort()
+			frame.ip = ip
+			calls := len(vm.frames)
+			vm.invoke(argCount, chunk.positions[start], op == OP_TAIL_INVOKE)
+			if op == OP_TAIL_INVOKE {
+				vm.replaceCaller(calls)
+			}
+			frame = &vm.frames[len(vm.frames)-1]
+			chunk = &frame.closure.function.Chunk
+			code, ip = chunk.Code, frame.ip
+		case OP_CLOSURE:
+			function := chunk.Constants[readShort()].ref.(*CompiledFunction)
+			closure := &Closure{function: function, upvalues: make([]*Upvalue, function.UpvalueCount)}
+			for i := range closure.upvalues {
+				isLocal := code[ip] != 0
+				ip++
+				index := readShort()
+				if isLocal {
+					closure.upvalues[i] = vm.captureUpvalue(frame.base + index)
+				} else {
+					closure.upvalues[i] = frame.closure.upvalues[index]
+				}
+			}
+			vm.push(objectValue(closure))
+		case OP_CLOSE_UPVALUE:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+		case OP_RETURN:
+			result := vm.pop()
+			vm.closeUpvalues(frame.base)
+			vm.stack = vm.stack[:frame.base]
+			if frame.traced {
+				in.popFrame()
+			}
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if len(vm.frames) == depth {
+				return result
+			}
+			vm.push(result)
+			frame = &vm.frames[len(vm.frames)-1]
+			chunk = &frame.closure.function.Chunk
+			code, ip = chunk.Code, frame.ip
+		case OP_CLASS:
+			name := chunk.Constants[readShort()].ref.(string)
+			hasSuperclass := code[ip] != 0
+			ip++
+			var superclass *LoxClass
+			if hasSuperclass {
+				var ok bool
+				if superclass, ok = vm.peek(0).ref.(*LoxClass); !ok {
+					pos := chunk.positions[start]
+					runtimeErrorSpan(pos.token, pos.span, "Superclass must be a class.")
+				}
+			}
+			vm.push(objectValue(newClass(name, superclass)))
+		case OP_METHOD:
+			name := chunk.Constants[readShort()].ref.(string)
+			method := vm.pop().ref.(*Closure)
+			class := vm.peek(0).ref.(*LoxClass)
+			method.class = class
+			class.methods[name] = method
+		case OP_LIST:
+			top := len(vm.stack) - readShort()
+			list := &LoxList{append([]Value(nil), vm.stack[top:]...)}
+			vm.stack = vm.stack[:top]
+			vm.push(objectValue(list))
+		case OP_INTERPOLATE:
+			top := len(vm.stack) - readShort()
+			value := interpolate(vm.stack[top:])
+			vm.stack = vm.stack[:top]
+			vm.push(value)
+		case OP_MAP:
+			top := len(vm.stack) - 2*readShort()
+			object, err := newMapFrom(vm.stack[top:])
+			if err != nil {
+				pos := chunk.positions[start]
+				runtimeErrorSpan(pos.token, pos.span, err.Error())
+			}
+			vm.stack = vm.stack[:top]
+			vm.push(objectValue(object))
+		case OP_GET_INDEX:
+			index := vm.pop()
+			value, err := getIndex(vm.pop(), index)
+			if err != nil {
+				pos := chunk.positions[start]
+				runtimeErrorSpan(pos.token, pos.span, err.Error())
+			}
+			vm.push(value)
+		case OP_SET_INDEX:
+			value := vm.pop()
+			index := vm.pop()
+			if err := setIndex(vm.pop(), index, value); err != nil {
+				pos := chunk.positions[start]
+				runtimeErrorSpan(pos.token, pos.span, err.Error())
+			}
+			vm.push(value)
+		case OP_WIDE:
+			wide = true
+		default:
+			panic(fmt.Sprintf("lox: unknown opcode %v", op))
+		}
+	}
+}
+
+func (vm *VM) getProperty(object Value, name string, pos position) Value {
+	switch object := object.ref.(type) {
+	case *LoxInstance:
+		if value, found := object.fields[name]; found {
+			return value
+		}
+		if method := object.class.FindMethod(name); method != nil {
+			return objectValue(method.Bind(object))
+		}
+		runtimeError(pos.token, "Undefined property '"+name+"'.")
+	case HostObject:
+		value, err := object.Get(vm.in, name)
+		if err != nil {
+			runtimeError(pos.token, err.Error())
+		}
+		return ValueOf(value)
+	case builtinObject:
+		if method := object.method(name); method != nil {
+			return objectValue(method)
+		}
+		runtimeError(pos.token, "Undefined property '"+name+"'.")
+	}
+	runtimeErrorSpan(pos.token, pos.span, "Only instances have properties.")
+	return nilValue
+}
+
+// callValue calls the value below the arguments on top of the stack. Calls
+// to compiled functions push a frame for run to carry on with. Anything else
+// is called right away and replaced by its result along with the arguments.
+// The position is that of a call expression, where the span covers the
+// callee.
+func (vm *VM) callValue(argCount int, pos position, tail bool) {
+	in := vm.in
+	slot := len(vm.stack) - argCount - 1
+	callSpan := Span{pos.span.Start, pos.token}
+	arityError := func(arity int) {
+		runtimeErrorSpan(pos.token, callSpan, fmt.Sprintf("Expected %d arguments but got %d.", arity, argCount))
+	}
+
+	switch callee := vm.stack[slot].ref.(type) {
+	case *Closure:
+		if argCount != callee.function.Arity {
+			arityError(callee.function.Arity)
+		}
+		vm.traceCall(callee, callee, pos.token, tail)
+		vm.frames = append(vm.frames, vmFrame{closure: callee, base: slot, traced: true})
+		return
+	case *BoundMethod:
+		if argCount != callee.method.function.Arity {
+			arityError(callee.method.function.Arity)
+		}
+		vm.traceCall(callee, callee.method, pos.token, tail)
+		vm.stack[slot] = objectValue(callee.receiver)
+		vm.frames = append(vm.frames, vmFrame{closure: callee.method, base: slot, traced: true})
+		return
+	case *LoxClass:
+		if initializer, ok := callee.FindMethod("init").(*Closure); ok {
+			if argCount != initializer.function.Arity {
+				arityError(initializer.function.Arity)
+			}
+			in.pushFrame(callee, pos.token)
+			vm.stack[slot] = objectValue(&LoxInstance{callee, make(map[string]Value)})
+			vm.frames = append(vm.frames, vmFrame{closure: initializer, base: slot, traced: true})
+			return
+		}
+	}
+
+	arguments := append([]Value(nil), vm.stack[slot+1:]...)
+	var result Value
+	switch callee := vm.stack[slot].ref.(type) {
+	case nativeCallable:
+		in.pushFrame(callee, pos.token)
+		var err error
+		result, err = callee.call(in, arguments)
+		in.popFrame()
+		if runtimeError, ok := err.(*RuntimeError); ok {
+			panic(runtimeError)
+		} else if err != nil {
+			runtimeErrorSpan(pos.token, callSpan, err.Error())
+		}
+	case LoxCallable:
+		if argCount != callee.Arity() {
+			arityError(callee.Arity())
+		}
+		in.pushFrame(callee, pos.token)
+		result = callee.Call(in, arguments)
+		in.popFrame()
+	default:
+		runtimeErrorSpan(pos.token, pos.span, "Can only call functions and classes.")
+	}
+	vm.stack = vm.stack[:slot]
+	vm.push(result)
+}
+
+// replaceCaller makes the frame a call in tail position pushed take the
+// place of its caller, given the number of frames before the call. Callees
+// that ran right away and initializers are left alone, as the tree-walker
+// does, and so are callers the VM didn't record in the call stack, which Go
+// code is waiting on.
+func (vm *VM) replaceCaller(calls int) {
+	if len(vm.frames) == calls || !vm.frames[calls-1].traced || vm.frames[calls].closure.function.isInitializer {
+		return
+	}
+	caller, callee := &vm.frames[calls-1], vm.frames[calls]
+	vm.closeUpvalues(caller.base)
+	n := copy(vm.stack[caller.base:], vm.stack[callee.base:])
+	vm.stack = vm.stack[:caller.base+n]
+	callee.base = caller.base
+	*caller = callee
+	vm.frames = vm.frames[:calls]
+}
+
+// traceCall records a call to a compiled function in the call stack. A call
+// in tail position that replaceCaller will make take its caller's place
+// replaces the caller's record instead.
+func (vm *VM) traceCall(callee LoxCallable, closure *Closure, paren *Token, tail bool) {
+	if tail && vm.frames[len(vm.frames)-1].traced && !closure.function.isInitializer {
+		vm.in.replaceFrame(callee, paren)
+		return
+	}
+	vm.in.pushFrame(callee, paren)
+}
+
+// invoke calls the method or property OP_GET_METHOD left below the
+// arguments, along with the receiver of a method or nil otherwise. A method
+// is called like a bound method, without creating one.
+func (vm *VM) invoke(argCount int, pos position, tail bool) {
+	slot := len(vm.stack) - argCount - 2
+	receiver := vm.stack[slot+1]
+	copy(vm.stack[slot+1:], vm.stack[slot+2:])
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	if receiver.kind == VK_NIL {
+		vm.callValue(argCount, pos, tail)
+		return
+	}
+	method := vm.stack[slot].ref.(*Closure)
+	if argCount != method.function.Arity {
+		callSpan := Span{pos.span.Start, pos.token}
+		runtimeErrorSpan(pos.token, callSpan, fmt.Sprintf("Expected %d arguments but got %d.", method.function.Arity, argCount))
+	}
+	vm.traceCall(method, method, pos.token, tail)
+	vm.stack[slot] = receiver
+	vm.frames = append(vm.frames, vmFrame{closure: method, base: slot, traced: true})
+}
@@ -0,0 +1,161 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// runBoth runs a program with the tree-walker and with the VM, and returns
+// what each printed followed by the error it reported, if any.
+func runBoth(t *testing.T, source string, setup func(in *Interpreter)) (walked, compiled string) {
+	t.Helper()
+	run := func(useVM bool) string {
+		in := NewInterpreter()
+		in.UseVM = useVM
+		var out strings.Builder
+		in.Stdout = &out
+		if setup != nil {
+			setup(in)
+		}
+		if err := in.Run([]byte(source)); err != nil {
+			out.WriteString(FormatError(err, "test.lox", []byte(source)))
+		}
+		return out.String()
+	}
+	return run(false), run(true)
+}
+
+func TestVMMatchesTreeWalker(t *testing.T) {
+	programs := []string{
+		`fun makeCounter() { var i = 0; fun count() { i = i + 1; return i; } return count; }
+		 var a = makeCounter(); var b = makeCounter();
+		 print a(); print a(); print b(); print a;`,
+		`var fs = nil;
+		 for (var i = 0; i < 3; i = i + 1) { var j = i; fun f() { print i + j; } if (i == 1) fs = f; }
+		 fs();`,
+		`{ var a = 1; fun f() { return a; } a = 2; print f(); }`,
+		`var nan = 0/0; print nan == nan; print nan <= 1; print nan >= 1;`,
+		`print 1 + 2 * 3 - 4 / 2; print "a" + "b"; print !nil; print nil or "x"; print false and 1;`,
+		`class A { init(n) { this.n = n; } get() { return this.n; } }
+		 class B < A { init(n) { super.init(n * 2); } get() { return super.get() + 1; } }
+		 var b = B(5); print b.get(); print b.init(1) == b; print b.get; print B; print b;`,
+		`class C { m() { fun inner() { return this; } return inner; } }
+		 var c = C(); print c.m()() == c; print c.m == c.m;`,
+		`class E { init() { this.a = 1; return; } } print E().a;`,
+		`fun fib(n) { if (n < 2) return n; return fib(n - 2) + fib(n - 1); } print fib(15);`,
+		`fun f(a) { return a + nil; }
+		 fun g() { return f(1); }
+		 g();`,
+		`class A { init(a, b) {} }
+		 A(1);`,
+		`print undefined;`,
+		`undefined = 1;`,
+		`fun side() { print "side"; return 1; }
+		 var n = nil; n.field = side();`,
+		`var a = 1; print a.b;`,
+		`class A {} print A().b;`,
+		`var NotAClass = "s"; class B < NotAClass {}`,
+		`"str"();`,
+		`class A { m() {} } class B < A { m() { super.nope(); } } B().m();`,
+		`fun f(x) { return clock(x); } f(1);`,
+		`print -"a";`,
+		`class A { name() { return "A"; } who() { return this.name(); } }
+		 class B < A { name() { return "B"; } }
+		 class C < B {}
+		 fun show(x) { print x.who(); }
+		 show(A()); show(B()); show(C()); show(A());
+		 fun f() { return "field"; } var a = A(); a.name = f; print a.name(); print a.who();`,
+		`class A { m(x) {} } fun side() { print "side"; return 1; } A().nope(side());`,
+		`class A { m(x) {} } fun side() { print "side"; return 1; } A().m(side(), side());`,
+		`fun even(n) { if (n == 0) return true; return odd(n - 1); }
+		 fun odd(n) { if (n == 0) return false; return even(n - 1); }
+		 class A { init() { this.n = 0; } m(n) { if (n > 0) return this.m(n - 1); return -this; } }
+		 fun make() { return A(); }
+		 print even(10); make().m(3);`,
+		`var l = [1, [2]]; l[1][0] = l; print l; print l.len; l.pop(); l.pop(); l.pop();`,
+		`print [1, 2][1.5];`,
+		`var s = "s"; s[0] = 1;`,
+		`[].insert(1, "x");`,
+		`var m = {}; m["m"] = m; m[m] = 1;`,
+		`class K {} var k = K(); var m = {k: 1}; m[K()] = 2; print m.len(); print m[k]; m.delete(0/0);`,
+	}
+	for _, program := range programs {
+		walked, compiled := runBoth(t, program, nil)
+		if walked != compiled {
+			t.Errorf("%s\ntree-walker:\n%s\nVM:\n%s", program, walked, compiled)
+		}
+	}
+}
+
+func TestSignedZeroConstants(t *testing.T) {
+	optimize := func(in *Interpreter) { in.Optimize = true }
+	walked, compiled := runBoth(t, `print -0; print 0; print -0 == 0;`, optimize)
+	if want := "-0\n0\ntrue\n"; walked != want || compiled != want {
+		t.Errorf("tree-walker printed %q and VM printed %q, want %q", walked, compiled, want)
+	}
+}
+
+func TestLargeChunks(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("var s = 0;\nif (true) {\n")
+	for i := range 70000 {
+		fmt.Fprintf(&sb, "s = s + %d;\n", i)
+	}
+	sb.WriteString("}\nclass A { m() { return this.name; } }\nvar a = A(); a.name = \"a\";\nprint s; print a.m();")
+	walked, compiled := runBoth(t, sb.String(), nil)
+	if want := "2449965000\na\n"; walked != want || compiled != want {
+		t.Errorf("tree-walker printed %q and VM printed %q, want %q", walked, compiled, want)
+	}
+}
+
+func TestVMNativeCallbacks(t *testing.T) {
+	setup := func(in *Interpreter) {
+		in.DefineNative(&NativeFunction{
+			Name:   "apply",
+			Params: []ParamType{PT_CALLABLE, PT_ANY},
+			Fn: func(in *Interpreter, arguments []any) (any, error) {
+				return in.Call(arguments[0], arguments[1])
+			},
+		})
+	}
+	programs := []string{
+		`fun twice(x) { return x * 2; }
+		 class A { init(x) { this.x = x; } m(y) { return this.x + y; } }
+		 print apply(twice, 21); print apply(A, 1).x; print apply(A(1).m, 2);
+		 fun outer() { var n = 1; fun add(x) { n = n + x; return n; } apply(add, 2); return n; }
+		 print outer();`,
+		"fun half(x) {\n  return x / 2;\n}\nfun run() {\n  return apply(half, \"ten\");\n}\nprint run();",
+	}
+	for _, program := range programs {
+		walked, compiled := runBoth(t, program, setup)
+		if walked != compiled {
+			t.Errorf("%s\ntree-walker:\n%s\nVM:\n%s", program, walked, compiled)
+		}
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	tokens, _ := Tokenize([]byte("var a = 1;\nprint a + 2;"))
+	statements, _ := NewParser(tokens).Parse()
+	in := NewInterpreter()
+	script, err := in.Compile(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	Disassemble(&out, script)
+	want := `== <script> ==
+0000    1 OP_CONSTANT         0 '1'
+0003    | OP_DEFINE_GLOBAL    1 'a'
+0006    2 OP_GET_GLOBAL       1 'a'
+0009    | OP_CONSTANT         1 '2'
+0012    | OP_ADD
+0013    | OP_PRINT
+0014    | OP_NIL
+0015    | OP_RETURN
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}